@@ -0,0 +1,101 @@
+package events
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/enrichman/api-fosdem/ical"
+	"github.com/enrichman/api-fosdem/pentabarf"
+	"github.com/go-kit/kit/endpoint"
+)
+
+type eventService interface {
+	FindByID(id, year int) (*Event, error)
+	Find(f Filter) ([]Event, int, error)
+	FindPentabarf(f Filter) ([]*pentabarf.Event, error)
+}
+
+func makeEventGetterEndpoint(finder eventService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(getEventByIDRequest)
+		return finder.FindByID(req.id, req.year)
+	}
+}
+
+type findResponse struct {
+	Count int     `json:"count"`
+	Data  []Event `json:"data"`
+}
+
+// Event maps the event
+type Event struct {
+	ID          int       `json:"id,omitempty"`
+	Slug        string    `json:"slug,omitempty"`
+	Title       string    `json:"title,omitempty"`
+	Subtitle    string    `json:"subtitle,omitempty"`
+	Track       string    `json:"track,omitempty"`
+	Type        string    `json:"type,omitempty"`
+	Language    string    `json:"language,omitempty"`
+	Room        string    `json:"room,omitempty"`
+	Day         int       `json:"day,omitempty"`
+	Date        string    `json:"date,omitempty"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Duration    int       `json:"duration,omitempty"`
+	Abstract    string    `json:"abstract,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Year        int       `json:"year,omitempty"`
+	Persons     []Person  `json:"persons,omitempty"`
+	Links       []Link    `json:"links,omitempty"`
+}
+
+// Person is a speaker of an Event
+type Person struct {
+	ID   int    `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// Link is a detail link owned by an Event
+type Link struct {
+	URL   string `json:"url,omitempty"`
+	Title string `json:"title,omitempty"`
+}
+
+func makeEventFinderEndpoint(finder eventService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(Filter)
+		events, count, err := finder.Find(req)
+		if err != nil {
+			return nil, err
+		}
+		return findResponse{
+			Count: count,
+			Data:  events,
+		}, nil
+	}
+}
+
+func makeCalendarEndpoint(finder eventService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(Filter)
+		events, err := finder.FindPentabarf(req)
+		if err != nil {
+			return nil, err
+		}
+
+		name := "FOSDEM"
+		if len(req.Years) > 0 {
+			years := make([]string, 0)
+			for _, y := range req.Years {
+				years = append(years, strconv.Itoa(y))
+			}
+			name += " " + strings.Join(years, ", ")
+		}
+		return ical.Calendar{
+			Name:   name,
+			Events: events,
+		}, nil
+	}
+}
@@ -0,0 +1,166 @@
+package events
+
+import (
+	"time"
+
+	"github.com/enrichman/api-fosdem/pentabarf"
+	"github.com/enrichman/api-fosdem/store"
+)
+
+type eventFinder interface {
+	FindEventByID(int, int) (*store.Event, error)
+	FindEvents(f store.EventFilter) ([]store.Event, int, error)
+	LatestYear() (int, error)
+}
+
+// Filter contains the parameters used to search through the events.
+// Empty fields are ignored, multiple values of the same field are in OR.
+type Filter struct {
+	Limit     int
+	Offset    int
+	IDs       []int
+	Years     []int
+	Days      []string
+	Rooms     []string
+	Tracks    []string
+	Types     []string
+	Languages []string
+	PersonIDs []int
+	From      time.Time
+	To        time.Time
+}
+
+type Service struct {
+	eventFinder eventFinder
+}
+
+func NewService(eventFinder eventFinder) *Service {
+	return &Service{eventFinder}
+}
+
+// FindByID returns the event of the year, or of the latest edition if year is 0
+func (s *Service) FindByID(id, year int) (*Event, error) {
+	if year == 0 {
+		latest, err := s.latestYear()
+		if err != nil {
+			return nil, err
+		}
+		year = latest
+	}
+
+	storeEvent, err := s.eventFinder.FindEventByID(id, year)
+	if err != nil {
+		return nil, err
+	}
+	event := convertEvent(*storeEvent)
+	return &event, nil
+}
+
+// Find returns the events matching the filter, of the latest edition if no years are passed
+func (s *Service) Find(f Filter) ([]Event, int, error) {
+	f, err := s.defaultYears(f)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	eventsFound, count, err := s.eventFinder.FindEvents(convertFilter(f))
+	if err != nil {
+		return nil, -1, err
+	}
+
+	events := make([]Event, 0)
+	for _, e := range eventsFound {
+		events = append(events, convertEvent(e))
+	}
+
+	return events, count, nil
+}
+
+// FindPentabarf returns all the events matching the filter, ignoring the paging
+func (s *Service) FindPentabarf(f Filter) ([]*pentabarf.Event, error) {
+	f, err := s.defaultYears(f)
+	if err != nil {
+		return nil, err
+	}
+
+	f.Limit, f.Offset = 0, 0
+	eventsFound, _, err := s.eventFinder.FindEvents(convertFilter(f))
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*pentabarf.Event, 0)
+	for _, e := range eventsFound {
+		events = append(events, e.ToPentabarf())
+	}
+	return events, nil
+}
+
+// defaultYears sets the latest edition in the filter if no years are passed
+func (s *Service) defaultYears(f Filter) (Filter, error) {
+	if len(f.Years) > 0 {
+		return f, nil
+	}
+	latest, err := s.latestYear()
+	if err != nil {
+		return f, err
+	}
+	f.Years = []int{latest}
+	return f, nil
+}
+
+// latestYear returns the last indexed edition, or the current year if nothing was indexed yet
+func (s *Service) latestYear() (int, error) {
+	year, err := s.eventFinder.LatestYear()
+	if err == store.ErrNotFound {
+		return time.Now().Year(), nil
+	}
+	return year, err
+}
+
+func convertFilter(f Filter) store.EventFilter {
+	return store.EventFilter{
+		Limit:     f.Limit,
+		Offset:    f.Offset,
+		IDs:       f.IDs,
+		Years:     f.Years,
+		Days:      f.Days,
+		Rooms:     f.Rooms,
+		Tracks:    f.Tracks,
+		Types:     f.Types,
+		Languages: f.Languages,
+		PersonIDs: f.PersonIDs,
+		From:      f.From,
+		To:        f.To,
+	}
+}
+
+func convertEvent(e store.Event) Event {
+	event := Event{
+		ID:          e.ID,
+		Slug:        e.Slug,
+		Title:       e.Title,
+		Subtitle:    e.Subtitle,
+		Track:       e.Track,
+		Type:        e.Type,
+		Language:    e.Language,
+		Room:        e.Room,
+		Day:         e.Day,
+		Date:        e.Date,
+		Start:       e.Start,
+		End:         e.End,
+		Duration:    int(e.Duration.Minutes()),
+		Abstract:    e.Abstract,
+		Description: e.Description,
+		Year:        e.Year,
+		Persons:     make([]Person, 0),
+		Links:       make([]Link, 0),
+	}
+	for _, p := range e.Persons {
+		event.Persons = append(event.Persons, Person{ID: p.ID, Name: p.Name})
+	}
+	for _, l := range e.Links {
+		event.Links = append(event.Links, Link{URL: l.URL, Title: l.Title})
+	}
+	return event
+}
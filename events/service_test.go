@@ -0,0 +1,128 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/enrichman/api-fosdem/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestStore returns a store with the conferences of the 2017 and of the 2018, and five events
+func newTestStore(t *testing.T) *store.MemoryStore {
+	ms := store.NewMemoryStore()
+	assert.Nil(t, ms.SaveConference(store.Conference{Year: 2017}))
+	assert.Nil(t, ms.SaveConference(store.Conference{Year: 2018}))
+
+	events := []struct {
+		id, year, day     int
+		room, track, lang string
+	}{
+		{1, 2018, 1, "H.1308", "Go", "en"},
+		{2, 2018, 1, "H.2214", "Rust", "en"},
+		{3, 2018, 2, "H.1308", "Go", "it"},
+		{4, 2018, 2, "Janson", "Keynotes", "en"},
+		{1, 2017, 1, "H.1308", "Go", "en"},
+	}
+	for i, e := range events {
+		start := time.Date(e.year, 2, 2+e.day, 10+i, 0, 0, 0, time.UTC)
+		assert.Nil(t, ms.SaveEvent(store.Event{
+			ID:       e.id,
+			Year:     e.year,
+			Slug:     "event_" + string(rune('a'+i)),
+			Title:    "Event",
+			Track:    e.track,
+			Type:     "devroom",
+			Language: e.lang,
+			Room:     e.room,
+			Day:      e.day,
+			Date:     start.Format("2006-01-02"),
+			Start:    start,
+			End:      start.Add(30 * time.Minute),
+			Duration: 30 * time.Minute,
+			Persons:  []store.Person{{ID: 10 + e.id, Name: "Speaker"}},
+			Links:    []store.Link{{URL: "https://fosdem.org/", Title: "FOSDEM"}},
+		}))
+	}
+	return ms
+}
+
+func TestServiceFind(t *testing.T) {
+	s := NewService(newTestStore(t))
+
+	tt := []struct {
+		name          string
+		filter        Filter
+		expectedIDs   []int
+		expectedCount int
+	}{
+		{name: "latest year", filter: Filter{}, expectedIDs: []int{1, 2, 3, 4}, expectedCount: 4},
+		{name: "year", filter: Filter{Years: []int{2017}}, expectedIDs: []int{1}, expectedCount: 1},
+		{name: "tracks", filter: Filter{Tracks: []string{"Go", "Rust"}}, expectedIDs: []int{1, 2, 3}, expectedCount: 3},
+		{name: "room and day", filter: Filter{Rooms: []string{"H.1308"}, Days: []string{"2"}}, expectedIDs: []int{3}, expectedCount: 1},
+		{name: "language", filter: Filter{Languages: []string{"it"}}, expectedIDs: []int{3}, expectedCount: 1},
+		{name: "person", filter: Filter{PersonIDs: []int{12, 14}}, expectedIDs: []int{2, 4}, expectedCount: 2},
+		{name: "paged", filter: Filter{Limit: 2, Offset: 1}, expectedIDs: []int{2, 3}, expectedCount: 4},
+		{name: "no events", filter: Filter{Tracks: []string{"Unknown"}}, expectedIDs: []int{}, expectedCount: 0},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			events, count, err := s.Find(tc.filter)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedCount, count)
+
+			ids := make([]int, 0)
+			for _, e := range events {
+				ids = append(ids, e.ID)
+			}
+			assert.Equal(t, tc.expectedIDs, ids)
+		})
+	}
+}
+
+func TestServiceFindByID(t *testing.T) {
+	s := NewService(newTestStore(t))
+
+	// the event of the latest edition if the year is not passed
+	event, err := s.FindByID(1, 0)
+	assert.Nil(t, err)
+	start := time.Date(2018, 2, 3, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, &Event{
+		ID:       1,
+		Slug:     "event_a",
+		Title:    "Event",
+		Track:    "Go",
+		Type:     "devroom",
+		Language: "en",
+		Room:     "H.1308",
+		Day:      1,
+		Date:     "2018-02-03",
+		Start:    start,
+		End:      start.Add(30 * time.Minute),
+		Duration: 30,
+		Year:     2018,
+		Persons:  []Person{{ID: 11, Name: "Speaker"}},
+		Links:    []Link{{URL: "https://fosdem.org/", Title: "FOSDEM"}},
+	}, event)
+
+	event, err = s.FindByID(1, 2017)
+	assert.Nil(t, err)
+	assert.Equal(t, "event_e", event.Slug)
+
+	_, err = s.FindByID(5, 2018)
+	assert.Equal(t, store.ErrNotFound, err)
+}
+
+func TestServiceFindPentabarf(t *testing.T) {
+	s := NewService(newTestStore(t))
+
+	// the paging is ignored
+	events, err := s.FindPentabarf(Filter{Limit: 1, Offset: 1, Tracks: []string{"Go"}})
+	assert.Nil(t, err)
+	assert.Len(t, events, 2)
+	for _, e := range events {
+		assert.Equal(t, "Go", e.Track)
+		assert.Equal(t, 30*time.Minute, e.Duration)
+	}
+}
@@ -0,0 +1,162 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/enrichman/api-fosdem/ical"
+	"github.com/enrichman/api-fosdem/store"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+)
+
+// MakeEventsHandler setup the handlers on the /api/v1/events route
+func MakeEventsHandler(s eventService) http.Handler {
+	r := mux.NewRouter().StrictSlash(true)
+
+	eventGetterHandler := kithttp.NewServer(
+		makeEventGetterEndpoint(s),
+		decodeEventGetter,
+		encodeEventGetter,
+	)
+
+	eventFinderHandler := kithttp.NewServer(
+		makeEventFinderEndpoint(s),
+		decodeEventFinder,
+		encodeEventFinder,
+	)
+
+	calendarHandler := kithttp.NewServer(
+		makeCalendarEndpoint(s),
+		decodeEventFinder,
+		encodeCalendar,
+	)
+
+	r.Handle("/api/v1/events", eventFinderHandler).Methods(http.MethodGet)
+	r.Handle("/api/v1/events.ics", calendarHandler).Methods(http.MethodGet)
+	r.Handle("/api/v1/events/{id}", eventGetterHandler).Methods(http.MethodGet)
+
+	return r
+}
+
+type getEventByIDRequest struct {
+	id   int
+	year int
+}
+
+func decodeEventGetter(_ context.Context, r *http.Request) (interface{}, error) {
+	var err error
+	var req getEventByIDRequest
+
+	vars := mux.Vars(r)
+	req.id, err = strconv.Atoi(vars["id"])
+	if err != nil {
+		return nil, errors.New("wrong ID")
+	}
+
+	if yearStr := r.FormValue("year"); yearStr != "" {
+		req.year, err = strconv.Atoi(yearStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+func encodeEventGetter(_ context.Context, w http.ResponseWriter, res interface{}) error {
+	return json.NewEncoder(w).Encode(res)
+}
+
+func decodeEventFinder(_ context.Context, r *http.Request) (interface{}, error) {
+	var err error
+	var req Filter
+
+	if err = r.ParseForm(); err != nil {
+		return nil, err
+	}
+
+	// the wrong parameters are answered with 400
+	if limit := r.FormValue("limit"); limit != "" {
+		req.Limit, err = strconv.Atoi(limit)
+		if err != nil || req.Limit < 0 {
+			return nil, store.InvalidFilterError{Reason: "wrong limit"}
+		}
+	}
+
+	if offset := r.FormValue("offset"); offset != "" {
+		req.Offset, err = strconv.Atoi(offset)
+		if err != nil || req.Offset < 0 {
+			return nil, store.InvalidFilterError{Reason: "wrong offset"}
+		}
+	}
+
+	req.Years, err = atoiList(r.Form["year"])
+	if err != nil {
+		return nil, store.InvalidFilterError{Reason: "wrong year"}
+	}
+
+	req.IDs, err = atoiList(r.Form["id"])
+	if err != nil {
+		return nil, store.InvalidFilterError{Reason: "wrong ID"}
+	}
+
+	req.PersonIDs, err = atoiList(r.Form["person"])
+	if err != nil {
+		return nil, store.InvalidFilterError{Reason: "wrong person"}
+	}
+
+	// names can contain commas, so multiple values are passed repeating the parameter
+	req.Days = r.Form["day"]
+	req.Rooms = r.Form["room"]
+	req.Tracks = r.Form["track"]
+	req.Types = r.Form["type"]
+	req.Languages = r.Form["language"]
+
+	if from := r.FormValue("from"); from != "" {
+		req.From, err = time.Parse(time.RFC3339, from)
+		if err != nil {
+			return nil, store.InvalidFilterError{Reason: "wrong from, not RFC 3339"}
+		}
+	}
+
+	if to := r.FormValue("to"); to != "" {
+		req.To, err = time.Parse(time.RFC3339, to)
+		if err != nil {
+			return nil, store.InvalidFilterError{Reason: "wrong to, not RFC 3339"}
+		}
+	}
+
+	if req.Limit == 0 || req.Limit > 100 {
+		req.Limit = 100
+	}
+	return req, nil
+}
+
+func encodeEventFinder(_ context.Context, w http.ResponseWriter, res interface{}) error {
+	return json.NewEncoder(w).Encode(res)
+}
+
+func encodeCalendar(_ context.Context, w http.ResponseWriter, res interface{}) error {
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	return ical.Write(w, res.(ical.Calendar))
+}
+
+// atoiList converts the comma separated values to int
+func atoiList(values []string) ([]int, error) {
+	ints := make([]int, 0)
+	for _, v := range values {
+		for _, p := range strings.Split(v, ",") {
+			i, err := strconv.Atoi(p)
+			if err != nil {
+				return nil, err
+			}
+			ints = append(ints, i)
+		}
+	}
+	return ints, nil
+}
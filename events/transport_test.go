@@ -0,0 +1,58 @@
+package events
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getEvents(handler http.Handler, url string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+	return rec
+}
+
+func TestEventFinderHandler(t *testing.T) {
+	handler := MakeEventsHandler(NewService(newTestStore(t)))
+
+	res := getEvents(handler, "/api/v1/events?track=Go&limit=1")
+	assert.Equal(t, http.StatusOK, res.Code)
+	var body struct {
+		Count int     `json:"count"`
+		Data  []Event `json:"data"`
+	}
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&body))
+	assert.Equal(t, 2, body.Count)
+	assert.Len(t, body.Data, 1)
+	assert.Equal(t, "event_a", body.Data[0].Slug)
+
+	tt := []struct {
+		name           string
+		url            string
+		expectedStatus int
+	}{
+		{name: "valid", url: "/api/v1/events?limit=10&offset=0&year=2017,2018&id=1&person=11", expectedStatus: http.StatusOK},
+		{name: "calendar", url: "/api/v1/events.ics?track=Go", expectedStatus: http.StatusOK},
+		{name: "negative limit", url: "/api/v1/events?limit=-1", expectedStatus: http.StatusBadRequest},
+		{name: "negative offset", url: "/api/v1/events?offset=-1", expectedStatus: http.StatusBadRequest},
+		{name: "wrong limit", url: "/api/v1/events?limit=ten", expectedStatus: http.StatusBadRequest},
+		{name: "wrong year", url: "/api/v1/events?year=2018,last", expectedStatus: http.StatusBadRequest},
+		{name: "wrong ID", url: "/api/v1/events?id=a", expectedStatus: http.StatusBadRequest},
+		{name: "wrong from", url: "/api/v1/events?from=2018-02-03", expectedStatus: http.StatusBadRequest},
+		{name: "calendar with a negative offset", url: "/api/v1/events.ics?offset=-1", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			res := getEvents(handler, tc.url)
+			assert.Equal(t, tc.expectedStatus, res.Code)
+			if tc.expectedStatus == http.StatusBadRequest {
+				assert.True(t, strings.Contains(res.Body.String(), "invalid filter"))
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package ical
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/enrichman/api-fosdem/pentabarf"
+	"golang.org/x/net/html"
+)
+
+const (
+	timezone       = "Europe/Brussels"
+	dateTimeFormat = "20060102T150405"
+	eventURLFormat = "https://fosdem.org/%d/schedule/event/%s/"
+	maxLineLength  = 75
+)
+
+// vtimezone describes the Europe/Brussels timezone, used by all the FOSDEM events
+var vtimezone = []string{
+	"BEGIN:VTIMEZONE",
+	"TZID:" + timezone,
+	"BEGIN:DAYLIGHT",
+	"TZOFFSETFROM:+0100",
+	"TZOFFSETTO:+0200",
+	"TZNAME:CEST",
+	"DTSTART:19700329T020000",
+	"RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU",
+	"END:DAYLIGHT",
+	"BEGIN:STANDARD",
+	"TZOFFSETFROM:+0200",
+	"TZOFFSETTO:+0100",
+	"TZNAME:CET",
+	"DTSTART:19701025T030000",
+	"RRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU",
+	"END:STANDARD",
+	"END:VTIMEZONE",
+}
+
+// Calendar contains the events to export
+type Calendar struct {
+	Name   string
+	Stamp  time.Time // the DTSTAMP of the events, now if zero
+	Events []*pentabarf.Event
+}
+
+// Write writes the Calendar as an iCalendar (RFC 5545) document
+func Write(w io.Writer, cal Calendar) error {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return err
+	}
+
+	stamp := cal.Stamp
+	if stamp.IsZero() {
+		stamp = time.Now()
+	}
+
+	bw := bufio.NewWriter(w)
+	lw := &lineWriter{w: bw}
+
+	lw.write("BEGIN:VCALENDAR")
+	lw.write("VERSION:2.0")
+	lw.write("PRODID:-//api-fosdem//FOSDEM schedule//EN")
+	lw.write("CALSCALE:GREGORIAN")
+	lw.write("METHOD:PUBLISH")
+	if cal.Name != "" {
+		lw.write("X-WR-CALNAME:" + escape(cal.Name))
+	}
+	lw.write("X-WR-TIMEZONE:" + timezone)
+	for _, l := range vtimezone {
+		lw.write(l)
+	}
+
+	for _, e := range cal.Events {
+		writeEvent(lw, e, stamp, location)
+	}
+
+	lw.write("END:VCALENDAR")
+
+	if lw.err != nil {
+		return lw.err
+	}
+	return bw.Flush()
+}
+
+func writeEvent(lw *lineWriter, e *pentabarf.Event, stamp time.Time, location *time.Location) {
+	start := e.Start.In(location)
+	end := start.Add(e.Duration)
+
+	lw.write("BEGIN:VEVENT")
+	lw.write("UID:" + UID(e))
+	lw.write("DTSTAMP:" + stamp.UTC().Format(dateTimeFormat) + "Z")
+	lw.write("DTSTART;TZID=" + timezone + ":" + start.Format(dateTimeFormat))
+	lw.write("DTEND;TZID=" + timezone + ":" + end.Format(dateTimeFormat))
+	lw.write("SUMMARY:" + escape(e.Title))
+	if description := eventDescription(e); description != "" {
+		lw.write("DESCRIPTION:" + escape(description))
+	}
+	if e.Room != "" {
+		lw.write("LOCATION:" + escape(e.Room))
+	}
+	if e.Track != "" {
+		lw.write("CATEGORIES:" + escape(e.Track))
+	}
+	if e.Slug != "" {
+		lw.write("URL:" + fmt.Sprintf(eventURLFormat, start.Year(), e.Slug))
+	}
+	lw.write("END:VEVENT")
+}
+
+// UID returns the stable unique identifier of the event, derived from the year and the ID of the event
+func UID(e *pentabarf.Event) string {
+	return fmt.Sprintf("%d-%d@fosdem.org", e.Start.Year(), e.ID)
+}
+
+func eventDescription(e *pentabarf.Event) string {
+	parts := make([]string, 0)
+	if e.Subtitle != "" {
+		parts = append(parts, e.Subtitle)
+	}
+	if len(e.Persons) > 0 {
+		names := make([]string, 0)
+		for _, p := range e.Persons {
+			names = append(names, p.Name)
+		}
+		parts = append(parts, "Speakers: "+strings.Join(names, ", "))
+	}
+	if abstract := htmlToText(e.Abstract); abstract != "" {
+		parts = append(parts, abstract)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// htmlToText strips the tags from the HTML abstract of the events
+func htmlToText(str string) string {
+	var text bytes.Buffer
+	tokenizer := html.NewTokenizer(strings.NewReader(str))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return strings.TrimSpace(text.String())
+		case html.TextToken:
+			text.Write(tokenizer.Text())
+		case html.EndTagToken:
+			if name, _ := tokenizer.TagName(); string(name) == "p" {
+				text.WriteString("\n\n")
+			}
+		}
+	}
+}
+
+// escape escapes the TEXT values as defined in RFC 5545, section 3.3.11
+func escape(str string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(str)
+}
+
+// lineWriter writes the content lines folding them at 75 octets (RFC 5545, section 3.1)
+type lineWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (lw *lineWriter) write(line string) {
+	if lw.err != nil {
+		return
+	}
+
+	folded := ""
+	limit := maxLineLength
+	for len(line) > limit {
+		// do not split multi-octet characters
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		folded += line[:cut] + "\r\n "
+		line = line[cut:]
+		limit = maxLineLength - 1
+	}
+	folded += line + "\r\n"
+
+	_, lw.err = io.WriteString(lw.w, folded)
+}
@@ -0,0 +1,107 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/enrichman/api-fosdem/pentabarf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrite(t *testing.T) {
+	location, _ := time.LoadLocation("Europe/Brussels")
+
+	cal := Calendar{
+		Name:  "FOSDEM 2018",
+		Stamp: time.Date(2018, time.January, 20, 10, 0, 0, 0, time.UTC),
+		Events: []*pentabarf.Event{{
+			ID:       5991,
+			Start:    time.Date(2018, time.February, 3, 10, 30, 0, 0, location),
+			Duration: 30 * time.Minute,
+			Room:     "H.1308 (Rolin)",
+			Slug:     "stateofgo",
+			Title:    "The State of Go",
+			Subtitle: "What's new in Go 1.10",
+			Track:    "Go",
+			Abstract: "<p>Go 1.10 is planned to be released in February 2018; this talk covers what's coming up with it.</p>",
+			Persons:  []*pentabarf.Person{{ID: 2072, Name: "Francesc Campoy"}},
+		}},
+	}
+
+	var buf bytes.Buffer
+	err := Write(&buf, cal)
+	assert.Nil(t, err)
+
+	out := buf.String()
+	assert.True(t, strings.HasPrefix(out, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.True(t, strings.HasSuffix(out, "END:VEVENT\r\nEND:VCALENDAR\r\n"))
+	assert.Contains(t, out, "BEGIN:VTIMEZONE\r\nTZID:Europe/Brussels\r\n")
+	assert.Contains(t, out, "X-WR-CALNAME:FOSDEM 2018\r\n")
+	assert.Contains(t, out, "UID:2018-5991@fosdem.org\r\n")
+	assert.Contains(t, out, "DTSTAMP:20180120T100000Z\r\n")
+	assert.Contains(t, out, "DTSTART;TZID=Europe/Brussels:20180203T103000\r\n")
+	assert.Contains(t, out, "DTEND;TZID=Europe/Brussels:20180203T110000\r\n")
+	assert.Contains(t, out, "SUMMARY:The State of Go\r\n")
+	assert.Contains(t, out, "LOCATION:H.1308 (Rolin)\r\n")
+	assert.Contains(t, out, "CATEGORIES:Go\r\n")
+	assert.Contains(t, out, "URL:https://fosdem.org/2018/schedule/event/stateofgo/\r\n")
+	assert.Contains(t, out, "DESCRIPTION:What's new in Go 1.10\\n\\nSpeakers: Francesc Campoy\\n\\nGo 1.10 i\r\n s planned")
+	assert.Contains(t, out, `released in February 2018\; this`)
+
+	for _, l := range strings.Split(out, "\r\n") {
+		assert.True(t, len(l) <= maxLineLength, "line too long: "+l)
+	}
+}
+
+func Test_escape(t *testing.T) {
+	tt := []struct {
+		name string
+		args string
+		exp  string
+	}{
+		{name: "plain text", args: "Keynotes", exp: "Keynotes"},
+		{name: "separators", args: "Embedded, mobile; automotive", exp: `Embedded\, mobile\; automotive`},
+		{name: "backslash and newlines", args: "a\\b\nc\r\nd", exp: `a\\b\nc\nd`},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.exp, escape(tc.args))
+		})
+	}
+}
+
+func Test_lineWriter(t *testing.T) {
+	tt := []struct {
+		name string
+		args string
+		exp  string
+	}{
+		{
+			name: "short line",
+			args: "SUMMARY:Go",
+			exp:  "SUMMARY:Go\r\n",
+		},
+		{
+			name: "folded line",
+			args: "SUMMARY:" + strings.Repeat("a", 100),
+			exp:  "SUMMARY:" + strings.Repeat("a", 67) + "\r\n " + strings.Repeat("a", 33) + "\r\n",
+		},
+		{
+			name: "multi-octet characters are not split",
+			args: strings.Repeat("a", 74) + "é",
+			exp:  strings.Repeat("a", 74) + "\r\n é\r\n",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			lw := &lineWriter{w: &buf}
+			lw.write(tc.args)
+
+			assert.Nil(t, lw.err)
+			assert.Equal(t, tc.exp, buf.String())
+		})
+	}
+}
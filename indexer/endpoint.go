@@ -12,12 +12,25 @@ type reindexRequest struct {
 }
 
 type reindexResponse struct {
-	Err error `json:"error"`
+	JobID string `json:"job_id,omitempty"`
+	Err   string `json:"error,omitempty"`
+}
+
+type getJobRequest struct {
+	token string
+	id    string
+}
+
+type getJobsResponse struct {
+	Data []*IndexReport `json:"data"`
 }
 
 type indexer interface {
 	GetToken() string
-	Index() error
+	StartJob() (*IndexReport, error)
+	GetJob(id string) (*IndexReport, error)
+	GetJobs() ([]*IndexReport, error)
+	CancelJob(id string) (*IndexReport, error)
 }
 
 func makeReindexEndpoint(indexer indexer) endpoint.Endpoint {
@@ -26,6 +39,56 @@ func makeReindexEndpoint(indexer indexer) endpoint.Endpoint {
 		if req.token != indexer.GetToken() {
 			return nil, errors.New("invalid token")
 		}
-		return reindexResponse{indexer.Index()}, nil
+
+		job, err := indexer.StartJob()
+		if err == ErrJobRunning {
+			return reindexResponse{JobID: job.ID, Err: err.Error()}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return reindexResponse{JobID: job.ID}, nil
+	}
+}
+
+func makeGetJobEndpoint(indexer indexer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(getJobRequest)
+		if req.token != indexer.GetToken() {
+			return nil, errors.New("invalid token")
+		}
+		return indexer.GetJob(req.id)
+	}
+}
+
+func makeGetJobsEndpoint(indexer indexer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(reindexRequest)
+		if req.token != indexer.GetToken() {
+			return nil, errors.New("invalid token")
+		}
+		reports, err := indexer.GetJobs()
+		if err != nil {
+			return nil, err
+		}
+		return getJobsResponse{reports}, nil
+	}
+}
+
+func makeCancelJobEndpoint(indexer indexer) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(getJobRequest)
+		if req.token != indexer.GetToken() {
+			return nil, errors.New("invalid token")
+		}
+
+		job, err := indexer.CancelJob(req.id)
+		if err == ErrJobNotRunning {
+			return reindexResponse{JobID: job.ID, Err: err.Error()}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return reindexResponse{JobID: job.ID}, nil
 	}
 }
@@ -1,8 +1,8 @@
 package indexer
 
 import (
-	"fmt"
-	"strconv"
+	"context"
+	"sync"
 	"time"
 
 	"github.com/enrichman/api-fosdem/pentabarf"
@@ -13,23 +13,44 @@ import (
 
 type speakerSaver interface {
 	Save(s store.Speaker) error
+	FindSpeakerByProfilePage(profilePage string) (*store.Speaker, error)
 }
 
 type scheduleGetter interface {
-	GetSchedule(year int) (*pentabarf.Schedule, error)
+	GetSchedule(ctx context.Context, year int) (*pentabarf.Schedule, error)
 }
 
 type speakerGetter interface {
-	GetSpeakers() <-chan web.Result
-	GetSpeakersByYear(int) <-chan web.Result
+	GetSpeakers(ctx context.Context, years ...int) <-chan web.Result
+	GetSpeakersByYear(ctx context.Context, year int) <-chan web.Result
 }
 
+// firstYear is the first edition of the FOSDEM with a schedule in the current format
+const firstYear = 2013
+
 // RemoteIndexer is an indexer that fetch the FOSDEM XML remotely
 type RemoteIndexer struct {
-	Token          string
+	Token string
+	// FirstYear and LastYear are the range of the editions to index.
+	// If LastYear is 0 the editions are discovered probing the schedules until the next year.
+	FirstYear int
+	LastYear  int
+
 	scheduleGetter scheduleGetter
 	speakerSaver   speakerSaver
+	scheduleSaver  scheduleSaver
 	speakerGetter  speakerGetter
+	reportStore    reportStore
+
+	mu      sync.Mutex
+	running *IndexReport
+	cancel  context.CancelFunc
+	done    chan struct{}
+	// unsaved are the last reports that could not be saved, the most recent first
+	unsaved []*IndexReport
+
+	observedMu sync.Mutex
+	observed   *progress
 }
 
 // NewRemoteIndexer returns a remoteIndexer
@@ -37,13 +58,18 @@ func NewRemoteIndexer(
 	token string,
 	scheduleGetter scheduleGetter,
 	speakerSaver speakerSaver,
+	scheduleSaver scheduleSaver,
 	speakerGetter speakerGetter,
+	reportStore reportStore,
 ) *RemoteIndexer {
 	return &RemoteIndexer{
 		Token:          token,
+		FirstYear:      firstYear,
 		scheduleGetter: scheduleGetter,
 		speakerSaver:   speakerSaver,
+		scheduleSaver:  scheduleSaver,
 		speakerGetter:  speakerGetter,
+		reportStore:    reportStore,
 	}
 }
 
@@ -52,66 +78,218 @@ func (fi *RemoteIndexer) GetToken() string {
 	return fi.Token
 }
 
-// Index starts the indexing
-func (fi *RemoteIndexer) Index() error {
-	start := time.Now()
-	fmt.Println(start, "start indexing")
+// Index indexes all the years as a job, returning the report of the indexing,
+// or ErrJobRunning and the running job if another one is running.
+// When the context is done the indexing stops, and the report is cancelled.
+func (fi *RemoteIndexer) Index(ctx context.Context) (*IndexReport, error) {
+	report, ctx, end, err := fi.startJob(ctx)
+	if err != nil {
+		return report, err
+	}
+	defer end()
+
+	err = fi.runIndex(ctx, report, fi.index)
+	return report, err
+}
+
+func (fi *RemoteIndexer) index(ctx context.Context, p *progress) error {
+	years, err := fi.Years(ctx)
+	if err != nil {
+		return err
+	}
+	p.yearsFound(years)
+
+	for _, year := range years {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// the errors of the years are collected in the report
+		fi.indexYear(ctx, year, p)
+	}
+	return ctx.Err()
+}
+
+// Years returns the editions to index, discovering them if a range was not configured.
+// The discovery stops when the context is done.
+func (fi *RemoteIndexer) Years(ctx context.Context) ([]int, error) {
+	years := make([]int, 0)
+	if fi.LastYear != 0 {
+		for year := fi.FirstYear; year <= fi.LastYear; year++ {
+			years = append(years, year)
+		}
+		return years, nil
+	}
 
-	for year := 2013; year <= 2018; year++ {
-		err := fi.IndexYear(year)
+	// the schedule of the next edition is usually published in the last months of the year
+	for year := fi.FirstYear; year <= time.Now().Year()+1; year++ {
+		_, err := fi.scheduleGetter.GetSchedule(ctx, year)
+		if err == pentabarf.ErrScheduleNotFound {
+			continue
+		}
 		if err != nil {
-			fmt.Println("error indexing year " + strconv.Itoa(year))
+			return nil, err
 		}
+		years = append(years, year)
 	}
+	return years, nil
+}
+
+// IndexYear index the provided year as a job, returning the report of the indexing,
+// or ErrJobRunning and the running job if another one is running
+func (fi *RemoteIndexer) IndexYear(ctx context.Context, year int) (*IndexReport, error) {
+	report, ctx, end, err := fi.startJob(ctx)
+	if err != nil {
+		return report, err
+	}
+	defer end()
 
-	fmt.Println(time.Since(start), "finished indexing")
-	return nil
+	err = fi.runIndex(ctx, report, func(ctx context.Context, p *progress) error {
+		return fi.indexYear(ctx, year, p)
+	})
+	return report, err
 }
 
-// IndexYear index the provided year
-func (fi *RemoteIndexer) IndexYear(year int) error {
-	schedule, err := fi.scheduleGetter.GetSchedule(year)
+func (fi *RemoteIndexer) indexYear(ctx context.Context, year int, p *progress) (err error) {
+	p.yearStarted(year)
+	defer func() { p.yearEnded(year, err) }()
+
+	schedule, err := fi.scheduleGetter.GetSchedule(ctx, year)
 	if err != nil {
 		return err
 	}
 
-	count := 0
-	for r := range fi.speakerGetter.GetSpeakersByYear(year) {
+	events, err := fi.saveSchedule(year, schedule)
+	if err != nil {
+		p.storeFailed(year, err, false)
+	}
+	p.eventsSaved(year, events)
+
+	err = fi.saveSnapshot(year, schedule)
+	if err != nil {
+		p.storeFailed(year, err, false)
+	}
+
+	// the speakers are saved after all of them are matched, as a person can't be two speakers
+	// and the match with the highest confidence wins
+	matcher := newPersonMatcher(schedule)
+	matched := make(map[int]speakerMatch)
+	order := make([]int, 0)
+	for r := range fi.speakerGetter.GetSpeakersByYear(ctx, year) {
 		if r.Error != nil {
-			fmt.Println("error getting speaker: " + r.Error.Error())
+			p.pageFailed(year, r.Error)
 			continue
 		}
+		p.speakerStarted(year, r.Speaker.Name)
 
-		p, found := schedule.GetPersonByName(r.Speaker.Name)
+		speaker := r.Speaker
+		var stored *store.Speaker
+		if r.Unchanged {
+			// the unchanged speakers are matched again with their stored events, as the schedule could have changed
+			stored, err = fi.speakerSaver.FindSpeakerByProfilePage(r.Speaker.ProfilePage)
+			if err != nil {
+				p.storeFailed(year, err, true)
+				continue
+			}
+			speaker.EventSlugs = stored.EventSlugs
+		}
+
+		m, found := matcher.match(speaker)
+		if !found && stored != nil {
+			// the speakers saved without their events keep their person, if still in the schedule
+			if person, ok := matcher.byID[stored.ID]; ok {
+				m, found = personMatch{person: person, method: stored.MatchMethod, confidence: stored.MatchConfidence}, true
+			}
+		}
 		if !found {
-			fmt.Println("person with name not found in schedule: " + r.Speaker.Name)
+			p.speakerUnmatched(year, r.Speaker.Name)
 			continue
 		}
 
-		s := store.Speaker{
-			ID:           p.ID,
-			Slug:         r.Speaker.Slug,
-			Name:         r.Speaker.Name,
-			ProfileImage: r.Speaker.ProfileImage,
-			ProfilePage:  r.Speaker.ProfilePage,
-			Bio:          r.Speaker.Bio,
-			Year:         r.Speaker.Year,
+		sm := speakerMatch{speaker: speaker, stored: stored, match: m}
+		other, claimed := matched[m.person.ID]
+		if !claimed {
+			matched[m.person.ID] = sm
+			order = append(order, m.person.ID)
+			continue
+		}
+		// on the same confidence the first speaker wins
+		if m.confidence > other.match.confidence {
+			matched[m.person.ID] = sm
+			p.speakerConflicted(year, other.speaker.Name, m.person.ID, speaker.Name)
+		} else {
+			p.speakerConflicted(year, speaker.Name, m.person.ID, other.speaker.Name)
 		}
+	}
 
-		s.Links = make([]store.Link, 0)
-		for _, l := range r.Speaker.Links {
-			s.Links = append(s.Links, store.Link{Title: l.Title, URL: l.URL})
+	for _, id := range order {
+		fi.saveSpeaker(year, matched[id], p)
+	}
+
+	// the speakers channel is closed early if the indexing was cancelled
+	return ctx.Err()
+}
+
+// speakerMatch is a speaker of the website matched to a person, with its stored record if unchanged
+type speakerMatch struct {
+	speaker web.Speaker
+	stored  *store.Speaker
+	match   personMatch
+}
+
+// saveSpeaker saves the matched speaker, if new or matched again to a different person.
+// The unchanged speakers are saved again only if the server sent new validators for the same page.
+func (fi *RemoteIndexer) saveSpeaker(year int, sm speakerMatch, p *progress) {
+	speaker, stored, m := sm.speaker, sm.stored, sm.match
+
+	var s store.Speaker
+	if stored != nil {
+		s = *stored
+		refreshed := speaker.Page.Hash != "" && speaker.Page.Hash == stored.PageHash &&
+			(speaker.Page.ETag != stored.PageETag || speaker.Page.LastModified != stored.PageLastModified)
+		if refreshed {
+			s.PageETag = speaker.Page.ETag
+			s.PageLastModified = speaker.Page.LastModified
 		}
 
-		err = fi.speakerSaver.Save(s)
-		if err != nil {
-			fmt.Println("error saving speaker: " + err.Error())
-			continue
+		if stored.ID == m.person.ID && stored.MatchMethod == m.method {
+			if refreshed {
+				if err := fi.speakerSaver.Save(s); err != nil {
+					p.storeFailed(year, err, true)
+					return
+				}
+			}
+			p.speakerUnchanged(year)
+			return
 		}
+		// otherwise the speaker is saved again with the new match
+	} else {
+		s = store.Speaker{
+			Slug:         speaker.Slug,
+			Name:         speaker.Name,
+			ProfileImage: speaker.ProfileImage,
+			ProfilePage:  speaker.ProfilePage,
+			Bio:          speaker.Bio,
+			Year:         speaker.Year,
+			EventSlugs:   speaker.EventSlugs,
 
-		count++
-		fmt.Printf("%d) year [%d] speaker [%s] saved\n", count, year, s.Name)
+			PageETag:         speaker.Page.ETag,
+			PageLastModified: speaker.Page.LastModified,
+			PageHash:         speaker.Page.Hash,
+		}
+
+		s.Links = make([]store.Link, 0)
+		for _, l := range speaker.Links {
+			s.Links = append(s.Links, store.Link{Title: l.Title, URL: l.URL})
+		}
 	}
+	s.ID = m.person.ID
+	s.MatchMethod = m.method
+	s.MatchConfidence = m.confidence
+	p.speakerMatched(year, speaker.Name, m)
 
-	return nil
+	if err := fi.speakerSaver.Save(s); err != nil {
+		p.storeFailed(year, err, true)
+		return
+	}
+	p.speakerSaved(year)
 }
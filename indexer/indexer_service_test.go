@@ -0,0 +1,650 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/enrichman/api-fosdem/pentabarf"
+	"github.com/enrichman/api-fosdem/retry"
+	"github.com/enrichman/api-fosdem/store"
+	"github.com/enrichman/api-fosdem/web"
+	"github.com/stretchr/testify/assert"
+)
+
+// localScheduleGetter returns the test schedule, with the abstract of the events if set
+type localScheduleGetter struct {
+	abstract string
+}
+
+func (g *localScheduleGetter) GetSchedule(ctx context.Context, year int) (*pentabarf.Schedule, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if year != 2018 {
+		return nil, pentabarf.ErrScheduleNotFound
+	}
+	f, err := os.Open("../pentabarf/pentabarf_test.xml")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	schedule, err := pentabarf.Parse(f)
+	if err != nil || g.abstract == "" {
+		return schedule, err
+	}
+	for _, e := range schedule.GetAllEvents() {
+		e.Abstract = g.abstract
+	}
+	return schedule, nil
+}
+
+type memorySaver struct {
+	mu        sync.Mutex
+	speakers  []store.Speaker
+	events    []store.Event
+	snapshots []store.Snapshot
+	reports   []store.Report
+	conf      *store.Conference
+	errSave   error
+	errReport error
+}
+
+func (s *memorySaver) Save(sp store.Speaker) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.errSave != nil {
+		return s.errSave
+	}
+	s.speakers = append(s.speakers, sp)
+	return nil
+}
+
+func (s *memorySaver) FindSpeakerByProfilePage(profilePage string) (*store.Speaker, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.speakers) - 1; i >= 0; i-- {
+		if s.speakers[i].ProfilePage == profilePage {
+			sp := s.speakers[i]
+			return &sp, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *memorySaver) SaveConference(c store.Conference) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conf = &c
+	return nil
+}
+
+func (s *memorySaver) FindConference(year int) (*store.Conference, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conf == nil || s.conf.Year != year {
+		return nil, store.ErrNotFound
+	}
+	conf := *s.conf
+	return &conf, nil
+}
+
+func (s *memorySaver) SaveDay(d store.Day) error     { return nil }
+func (s *memorySaver) SaveRoom(r store.Room) error   { return nil }
+func (s *memorySaver) SaveTrack(t store.Track) error { return nil }
+
+func (s *memorySaver) SaveEvent(e store.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+	return nil
+}
+
+func (s *memorySaver) DeleteEvents(year int, keepIDs []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keep := make(map[int]bool)
+	for _, ID := range keepIDs {
+		keep[ID] = true
+	}
+	events := make([]store.Event, 0)
+	for _, e := range s.events {
+		if e.Year != year || keep[e.ID] {
+			events = append(events, e)
+		}
+	}
+	s.events = events
+	return nil
+}
+
+func (s *memorySaver) SaveSnapshot(sn store.Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots = append(s.snapshots, sn)
+	return nil
+}
+
+func (s *memorySaver) FindLatestSnapshot(year int) (*store.Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.snapshots) == 0 {
+		return nil, store.ErrNotFound
+	}
+	return &s.snapshots[len(s.snapshots)-1], nil
+}
+
+func (s *memorySaver) SaveReport(r store.Report) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.errReport != nil {
+		return s.errReport
+	}
+	s.reports = append(s.reports, r)
+	return nil
+}
+
+func (s *memorySaver) FindReport(id string) (*store.Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.reports {
+		if r.ID == id {
+			return &r, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *memorySaver) FindReports(limit int) ([]store.Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]store.Report{}, s.reports...), nil
+}
+
+type localSpeakerGetter struct {
+	results []web.Result
+}
+
+func (g *localSpeakerGetter) GetSpeakers(ctx context.Context, years ...int) <-chan web.Result {
+	return g.GetSpeakersByYear(ctx, 0)
+}
+
+func (g *localSpeakerGetter) GetSpeakersByYear(ctx context.Context, year int) <-chan web.Result {
+	c := make(chan web.Result)
+	go func() {
+		for _, r := range g.results {
+			c <- r
+		}
+		close(c)
+	}()
+	return c
+}
+
+var testResults = []web.Result{
+	{Speaker: web.Speaker{Slug: "mario_rossi", Name: "Mario Rossi", Year: 2018}},
+	{Speaker: web.Speaker{Slug: "unknown", Name: "Unknown Speaker", Year: 2018}},
+	{Error: &web.PageError{URL: "https://fosdem.org/2018/schedule/speaker/broken/", Err: errors.New("main div not found")}},
+}
+
+func TestIndexYear(t *testing.T) {
+	saver := &memorySaver{}
+	fi := NewRemoteIndexer("token", &localScheduleGetter{}, saver, saver, &localSpeakerGetter{testResults}, saver)
+
+	report, err := fi.IndexYear(context.Background(), 2018)
+	assert.Nil(t, err)
+	assert.Equal(t, StatusCompleted, report.Status)
+	assert.Equal(t, []*YearReport{{
+		Year:              2018,
+		Status:            StatusCompleted,
+		Events:            6,
+		Speakers:          2,
+		Saved:             1,
+		Skipped:           1,
+		Failed:            1,
+		UnmatchedSpeakers: []string{"Unknown Speaker"},
+		UncertainMatches:  []SpeakerMatch{},
+		Conflicts:         []SpeakerConflict{},
+		PageFailures: []PageFailure{{
+			URL:   "https://fosdem.org/2018/schedule/speaker/broken/",
+			Error: "main div not found",
+		}},
+		StoreErrors: []string{},
+	}}, report.Years)
+
+	assert.Equal(t, []store.Speaker{{
+		ID:              1,
+		Slug:            "mario_rossi",
+		Name:            "Mario Rossi",
+		Year:            2018,
+		Links:           []store.Link{},
+		MatchMethod:     MatchSlug,
+		MatchConfidence: 0.95,
+	}}, saver.speakers)
+	assert.Len(t, saver.events, 6)
+	assert.Len(t, saver.snapshots, 1)
+
+	// a reindex of the same schedule does not create a new snapshot
+	_, err = fi.IndexYear(context.Background(), 2018)
+	assert.Nil(t, err)
+	assert.Len(t, saver.snapshots, 1)
+}
+
+func TestIndexYearConflict(t *testing.T) {
+	slug := web.Result{Speaker: web.Speaker{Slug: "mario_rossi", Name: "Mario Rossi", Year: 2018}}
+	name := web.Result{Speaker: web.Speaker{Slug: "mrossi", Name: "mario rossi", Year: 2018}}
+	fuzzy := web.Result{Speaker: web.Speaker{Slug: "mrosi", Name: "Mario Rosi", Year: 2018}}
+
+	tt := []struct {
+		name             string
+		results          []web.Result
+		expectedSlug     string
+		expectedConflict SpeakerConflict
+	}{
+		{
+			name:             "the first speaker wins on the same confidence",
+			results:          []web.Result{name, {Speaker: web.Speaker{Slug: "m_rossi", Name: "Mario Rossi", Year: 2018}}},
+			expectedSlug:     "mrossi",
+			expectedConflict: SpeakerConflict{Name: "Mario Rossi", PersonID: 1, MatchedTo: "mario rossi"},
+		},
+		{
+			name:             "the weaker match arrived later",
+			results:          []web.Result{slug, fuzzy},
+			expectedSlug:     "mario_rossi",
+			expectedConflict: SpeakerConflict{Name: "Mario Rosi", PersonID: 1, MatchedTo: "Mario Rossi"},
+		},
+		{
+			name:             "the weaker match arrived first",
+			results:          []web.Result{fuzzy, slug},
+			expectedSlug:     "mario_rossi",
+			expectedConflict: SpeakerConflict{Name: "Mario Rosi", PersonID: 1, MatchedTo: "Mario Rossi"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			saver := &memorySaver{}
+			fi := NewRemoteIndexer("token", &localScheduleGetter{}, saver, saver, &localSpeakerGetter{tc.results}, saver)
+
+			// only one of the speakers matched to the same person is saved
+			report, err := fi.IndexYear(context.Background(), 2018)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, report.Years[0].Saved)
+			assert.Equal(t, 1, report.Years[0].Skipped)
+			assert.Empty(t, report.Years[0].UnmatchedSpeakers)
+			assert.Empty(t, report.Years[0].UncertainMatches)
+			assert.Equal(t, []SpeakerConflict{tc.expectedConflict}, report.Years[0].Conflicts)
+			assert.Len(t, saver.speakers, 1)
+			assert.Equal(t, tc.expectedSlug, saver.speakers[0].Slug)
+		})
+	}
+}
+
+func TestIndexYearScheduleUpdated(t *testing.T) {
+	saver := &memorySaver{}
+	getter := &localScheduleGetter{}
+	fi := NewRemoteIndexer("token", getter, saver, saver, &localSpeakerGetter{}, saver)
+
+	_, err := fi.IndexYear(context.Background(), 2018)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, saver.conf.ScheduleHash)
+	updatedAt := saver.conf.UpdatedAt
+	assert.False(t, updatedAt.IsZero())
+
+	// the time is kept if the schedule did not change
+	_, err = fi.IndexYear(context.Background(), 2018)
+	assert.Nil(t, err)
+	assert.Equal(t, updatedAt, saver.conf.UpdatedAt)
+
+	// and changes with any field of the events, also not saved in the snapshots
+	getter.abstract = "new abstract"
+	_, err = fi.IndexYear(context.Background(), 2018)
+	assert.Nil(t, err)
+	assert.True(t, saver.conf.UpdatedAt.After(updatedAt))
+	assert.Len(t, saver.snapshots, 1)
+}
+
+func TestIndexYearRemovedEvents(t *testing.T) {
+	saver := &memorySaver{events: []store.Event{{ID: 999, Year: 2018}, {ID: 999, Year: 2017}}}
+	fi := NewRemoteIndexer("token", &localScheduleGetter{}, saver, saver, &localSpeakerGetter{}, saver)
+
+	_, err := fi.IndexYear(context.Background(), 2018)
+	assert.Nil(t, err)
+
+	// the event removed from the schedule is deleted, the ones of the other years are kept
+	assert.Len(t, saver.events, 7)
+	for _, e := range saver.events {
+		assert.False(t, e.ID == 999 && e.Year == 2018)
+	}
+}
+
+func TestIndexYearUnchanged(t *testing.T) {
+	paolo := "https://fosdem.org/2018/schedule/speaker/pbianchi/"
+	tt := []struct {
+		name              string
+		stored            store.Speaker
+		page              web.PageState
+		expectedSaved     int
+		expectedUnchanged int
+		expected          store.Speaker
+	}{
+		{
+			name:              "same match",
+			stored:            store.Speaker{ID: 2, Slug: "pbianchi", Name: "P. B.", Year: 2018, ProfilePage: paolo, Bio: "A bio", EventSlugs: []string{"event_124_slug"}, MatchMethod: MatchEvent, MatchConfidence: 1},
+			expectedSaved:     1,
+			expectedUnchanged: 1,
+		},
+		{
+			name:              "person renumbered in the schedule",
+			stored:            store.Speaker{ID: 9, Slug: "pbianchi", Name: "P. B.", Year: 2018, ProfilePage: paolo, Bio: "A bio", EventSlugs: []string{"event_124_slug"}, MatchMethod: MatchEvent, MatchConfidence: 1},
+			expectedSaved:     2,
+			expectedUnchanged: 0,
+			expected:          store.Speaker{ID: 2, Slug: "pbianchi", Name: "P. B.", Year: 2018, ProfilePage: paolo, Bio: "A bio", EventSlugs: []string{"event_124_slug"}, MatchMethod: MatchEvent, MatchConfidence: 1},
+		},
+		{
+			name:              "match method changed",
+			stored:            store.Speaker{ID: 2, Slug: "paolo_bianchi", Name: "Paolo Bianchi", Year: 2018, ProfilePage: paolo, MatchMethod: MatchFuzzy, MatchConfidence: 0.8},
+			expectedSaved:     2,
+			expectedUnchanged: 0,
+			expected:          store.Speaker{ID: 2, Slug: "paolo_bianchi", Name: "Paolo Bianchi", Year: 2018, ProfilePage: paolo, MatchMethod: MatchSlug, MatchConfidence: 0.95},
+		},
+		{
+			name:              "same page with new validators",
+			stored:            store.Speaker{ID: 2, Slug: "pbianchi", Name: "P. B.", Year: 2018, ProfilePage: paolo, EventSlugs: []string{"event_124_slug"}, PageETag: `"v1"`, PageHash: "def", MatchMethod: MatchEvent, MatchConfidence: 1},
+			page:              web.PageState{ETag: `"v2"`, LastModified: "Sat, 20 Jan 2018 10:00:00 GMT", Hash: "def"},
+			expectedSaved:     1,
+			expectedUnchanged: 1,
+			expected:          store.Speaker{ID: 2, Slug: "pbianchi", Name: "P. B.", Year: 2018, ProfilePage: paolo, EventSlugs: []string{"event_124_slug"}, PageETag: `"v2"`, PageLastModified: "Sat, 20 Jan 2018 10:00:00 GMT", PageHash: "def", MatchMethod: MatchEvent, MatchConfidence: 1},
+		},
+		{
+			name:              "same page with the same validators",
+			stored:            store.Speaker{ID: 2, Slug: "pbianchi", Name: "P. B.", Year: 2018, ProfilePage: paolo, EventSlugs: []string{"event_124_slug"}, PageETag: `"v1"`, PageHash: "def", MatchMethod: MatchEvent, MatchConfidence: 1},
+			page:              web.PageState{ETag: `"v1"`, Hash: "def"},
+			expectedSaved:     1,
+			expectedUnchanged: 1,
+		},
+		{
+			name:              "saved without the events",
+			stored:            store.Speaker{ID: 2, Slug: "pbianchi", Name: "P. B.", Year: 2018, ProfilePage: paolo, MatchMethod: MatchEvent, MatchConfidence: 1},
+			expectedSaved:     1,
+			expectedUnchanged: 1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			saver := &memorySaver{speakers: []store.Speaker{tc.stored}}
+			results := []web.Result{
+				{Speaker: web.Speaker{Slug: "mario_rossi", Name: "Mario Rossi", Year: 2018, Page: web.PageState{ETag: `"v2"`, Hash: "abc"}}},
+				{Speaker: web.Speaker{Slug: tc.stored.Slug, Name: tc.stored.Name, Year: 2018, ProfilePage: paolo, Page: tc.page}, Unchanged: true},
+			}
+			fi := NewRemoteIndexer("token", &localScheduleGetter{}, saver, saver, &localSpeakerGetter{results}, saver)
+
+			report, err := fi.IndexYear(context.Background(), 2018)
+			assert.Nil(t, err)
+			assert.Equal(t, 2, report.Years[0].Speakers)
+			assert.Equal(t, tc.expectedSaved, report.Years[0].Saved)
+			assert.Equal(t, tc.expectedUnchanged, report.Years[0].Unchanged)
+			assert.Equal(t, tc.expectedUnchanged, report.Unchanged)
+			assert.Empty(t, report.Years[0].UnmatchedSpeakers)
+
+			assert.Equal(t, store.Speaker{
+				ID:       1,
+				Slug:     "mario_rossi",
+				Name:     "Mario Rossi",
+				Year:     2018,
+				Links:    []store.Link{},
+				PageETag: `"v2"`,
+				PageHash: "abc",
+
+				MatchMethod:     MatchSlug,
+				MatchConfidence: 0.95,
+			}, saver.speakers[1])
+			// the speaker is saved again with the new match or the new validators
+			if tc.expected.ID != 0 {
+				assert.Equal(t, tc.expected, saver.speakers[2])
+			} else {
+				assert.Len(t, saver.speakers, 2)
+			}
+		})
+	}
+}
+
+func TestRetriesRecorded(t *testing.T) {
+	fi := NewRemoteIndexer("token", &localScheduleGetter{}, nil, nil, nil, nil)
+
+	report := newIndexReport()
+	fi.observe(newProgress(&sync.Mutex{}, report))
+	fi.Retried(retry.Attempt{
+		Target: "https://fosdem.org/2018/schedule/xml",
+		Number: 1,
+		Err:    &retry.StatusError{StatusCode: 503},
+		Wait:   time.Second,
+	})
+	fi.StateChanged(retry.Closed, retry.Open)
+	fi.observe(nil)
+
+	// without a running indexing the retries are not recorded
+	fi.Retried(retry.Attempt{Target: "https://fosdem.org/2018/schedule/xml", Number: 2})
+
+	assert.Len(t, report.Attempts, 1)
+	assert.Equal(t, "https://fosdem.org/2018/schedule/xml", report.Attempts[0].URL)
+	assert.Equal(t, "unexpected status 503 Service Unavailable", report.Attempts[0].Error)
+	assert.Equal(t, "1s", report.Attempts[0].Wait)
+	assert.Len(t, report.BreakerChanges, 1)
+	assert.Equal(t, "closed", report.BreakerChanges[0].From)
+	assert.Equal(t, "open", report.BreakerChanges[0].To)
+
+	// the attempts are persisted with the report
+	assert.Equal(t, report, convertStoreReport(convertReport(report)))
+}
+
+func TestYears(t *testing.T) {
+	fi := NewRemoteIndexer("token", &localScheduleGetter{}, nil, nil, nil, nil)
+
+	years, err := fi.Years(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []int{2018}, years)
+
+	// the discovery stops when the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = fi.Years(ctx)
+	assert.Equal(t, context.Canceled, err)
+
+	fi.FirstYear, fi.LastYear = 2015, 2017
+	years, err = fi.Years(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []int{2015, 2016, 2017}, years)
+}
+
+func waitJob(t *testing.T, fi *RemoteIndexer, id string) *IndexReport {
+	for i := 0; i < 100; i++ {
+		job, err := fi.GetJob(id)
+		assert.Nil(t, err)
+		if job.EndedAt != nil {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("job not ended")
+	return nil
+}
+
+func TestStartJob(t *testing.T) {
+	saver := &memorySaver{}
+	fi := NewRemoteIndexer("token", &localScheduleGetter{}, saver, saver, &localSpeakerGetter{testResults}, saver)
+	fi.FirstYear, fi.LastYear = 2017, 2018
+
+	job, err := fi.StartJob()
+	assert.Nil(t, err)
+
+	_, err = fi.StartJob()
+	if err != nil {
+		assert.Equal(t, ErrJobRunning, err)
+	}
+
+	job = waitJob(t, fi, job.ID)
+	assert.Equal(t, StatusCompleted, job.Status)
+	assert.Equal(t, 1, job.Saved)
+	assert.Equal(t, 1, job.Skipped)
+	assert.Equal(t, 1, job.Failed)
+	assert.Len(t, job.Years, 2)
+	assert.Equal(t, StatusFailed, job.Years[0].Status)
+	assert.Equal(t, "schedule not found", job.Years[0].Error)
+	assert.Equal(t, StatusCompleted, job.Years[1].Status)
+
+	// the report is persisted
+	jobs, err := fi.GetJobs()
+	assert.Nil(t, err)
+	assert.Equal(t, job, jobs[0])
+
+	_, err = fi.GetJob("missing")
+	assert.Equal(t, ErrJobNotFound, err)
+}
+
+// blockingSpeakerGetter returns no speakers until the context is done
+type blockingSpeakerGetter struct{}
+
+func (g *blockingSpeakerGetter) GetSpeakers(ctx context.Context, years ...int) <-chan web.Result {
+	return g.GetSpeakersByYear(ctx, 0)
+}
+
+func (g *blockingSpeakerGetter) GetSpeakersByYear(ctx context.Context, year int) <-chan web.Result {
+	c := make(chan web.Result)
+	go func() {
+		<-ctx.Done()
+		close(c)
+	}()
+	return c
+}
+
+func TestCancelJob(t *testing.T) {
+	saver := &memorySaver{}
+	fi := NewRemoteIndexer("token", &localScheduleGetter{}, saver, saver, &blockingSpeakerGetter{}, saver)
+	fi.FirstYear, fi.LastYear = 2018, 2019
+
+	job, err := fi.StartJob()
+	assert.Nil(t, err)
+
+	// wait until the speakers of the first year are requested
+	for i := 0; i < 100; i++ {
+		job, err = fi.GetJob(job.ID)
+		assert.Nil(t, err)
+		if len(job.Years) > 0 && job.Years[0].Events > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_, err = fi.CancelJob("unknown")
+	assert.Equal(t, ErrJobNotFound, err)
+
+	_, err = fi.CancelJob(job.ID)
+	assert.Nil(t, err)
+
+	job = waitJob(t, fi, job.ID)
+	assert.Equal(t, StatusCancelled, job.Status)
+	assert.Equal(t, StatusCancelled, job.Years[0].Status)
+	// the next year is not indexed
+	assert.Equal(t, StatusPending, job.Years[1].Status)
+
+	_, err = fi.CancelJob(job.ID)
+	assert.Equal(t, ErrJobNotRunning, err)
+}
+
+func TestShutdown(t *testing.T) {
+	saver := &memorySaver{}
+	fi := NewRemoteIndexer("token", &localScheduleGetter{}, saver, saver, &blockingSpeakerGetter{}, saver)
+	fi.FirstYear, fi.LastYear = 2018, 2018
+
+	job, err := fi.StartJob()
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, fi.Shutdown(ctx))
+
+	// the report is saved before the shutdown returns
+	saved, err := fi.GetJob(job.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, StatusCancelled, saved.Status)
+}
+
+func TestIndexYearJobRunning(t *testing.T) {
+	saver := &memorySaver{}
+	fi := NewRemoteIndexer("token", &localScheduleGetter{}, saver, saver, &blockingSpeakerGetter{}, saver)
+	fi.FirstYear, fi.LastYear = 2018, 2018
+
+	job, err := fi.StartJob()
+	assert.Nil(t, err)
+	for i := 0; i < 100 && fi.observedProgress() == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the running job is returned, and its progress is not replaced
+	running, err := fi.IndexYear(context.Background(), 2018)
+	assert.Equal(t, ErrJobRunning, err)
+	assert.Equal(t, job.ID, running.ID)
+	_, err = fi.Index(context.Background())
+	assert.Equal(t, ErrJobRunning, err)
+	if p := fi.observedProgress(); assert.NotNil(t, p) {
+		assert.Equal(t, job.ID, p.report.ID)
+	}
+
+	_, err = fi.CancelJob(job.ID)
+	assert.Nil(t, err)
+	waitJob(t, fi, job.ID)
+
+	// a job can't start while the year is indexed
+	fi = NewRemoteIndexer("token", &localScheduleGetter{}, saver, saver, &blockingSpeakerGetter{}, saver)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		report, err := fi.IndexYear(ctx, 2018)
+		assert.Equal(t, context.Canceled, err)
+		assert.Equal(t, StatusCancelled, report.Status)
+	}()
+	for i := 0; i < 100 && fi.observedProgress() == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	_, err = fi.StartJob()
+	assert.Equal(t, ErrJobRunning, err)
+	cancel()
+	<-done
+}
+
+func TestReportNotSaved(t *testing.T) {
+	saver := &memorySaver{errReport: errors.New("store down")}
+	fi := NewRemoteIndexer("token", &localScheduleGetter{}, saver, saver, &localSpeakerGetter{}, saver)
+
+	report, err := fi.IndexYear(context.Background(), 2018)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"error saving report: store down"}, report.Errors)
+
+	// the report is still returned with the jobs
+	job, err := fi.GetJob(report.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, report, job)
+
+	fi.jobFailed(errors.New("error starting scheduled reindex: store down"))
+	jobs, err := fi.GetJobs()
+	assert.Nil(t, err)
+	assert.Len(t, jobs, 2)
+	assert.Equal(t, StatusFailed, jobs[0].Status)
+	assert.Equal(t, []string{"error starting scheduled reindex: store down", "error saving report: store down"}, jobs[0].Errors)
+	assert.Equal(t, report, jobs[1])
+}
+
+func Test_cancelled(t *testing.T) {
+	assert.True(t, cancelled(context.Canceled))
+	assert.True(t, cancelled(&url.Error{Op: "Get", URL: "https://fosdem.org/2018/schedule/xml", Err: context.Canceled}))
+	assert.True(t, cancelled(&url.Error{Op: "Get", Err: &net.OpError{Op: "dial", Err: context.Canceled}}))
+	assert.False(t, cancelled(nil))
+	assert.False(t, cancelled(context.DeadlineExceeded))
+	assert.False(t, cancelled(errors.New("schedule not found")))
+}
@@ -0,0 +1,167 @@
+package indexer
+
+import (
+	"sort"
+
+	"github.com/enrichman/api-fosdem/names"
+	"github.com/enrichman/api-fosdem/pentabarf"
+	"github.com/enrichman/api-fosdem/web"
+)
+
+// the methods used to match a speaker of the website to a person of the schedule
+const (
+	// MatchEvent is a match through the events listed in the profile page of the speaker
+	MatchEvent = "event"
+	// MatchSlug is a match of the slug of the profile page with the slug of the name of the person
+	MatchSlug = "slug"
+	// MatchName is a match of the names, without accents, punctuation and extra spaces
+	MatchName = "name"
+	// MatchFuzzy is a match of similar names
+	MatchFuzzy = "fuzzy"
+)
+
+const (
+	// minFuzzySimilarity is the minimum similarity of the names for a fuzzy match
+	minFuzzySimilarity = 0.85
+	// minEventSimilarity is the minimum similarity of the names to choose between the persons of the same events
+	minEventSimilarity = 0.5
+	// reviewConfidence is the confidence below which a match is listed in the report for review
+	reviewConfidence = 0.9
+)
+
+// personMatch is the person of the schedule matched to a speaker
+type personMatch struct {
+	person     *pentabarf.Person
+	method     string
+	confidence float64
+}
+
+// personMatcher matches the speakers of the website to the persons of a schedule
+type personMatcher struct {
+	persons []*pentabarf.Person
+	byID    map[int]*pentabarf.Person
+	bySlug  map[string]*pentabarf.Person
+	byName  map[string]*pentabarf.Person
+	byEvent map[string][]*pentabarf.Person
+}
+
+func newPersonMatcher(schedule *pentabarf.Schedule) *personMatcher {
+	// the persons are collected by ID, the persons of the schedule are unique only by name
+	byID := make(map[int]*pentabarf.Person)
+	for _, e := range schedule.GetAllEvents() {
+		for _, p := range e.Persons {
+			byID[p.ID] = p
+		}
+	}
+	persons := make([]*pentabarf.Person, 0, len(byID))
+	for _, p := range byID {
+		persons = append(persons, p)
+	}
+	// the lowest ID wins if two persons have the same slug or name
+	sort.Slice(persons, func(i, j int) bool { return persons[i].ID < persons[j].ID })
+
+	m := &personMatcher{
+		persons: persons,
+		byID:    byID,
+		bySlug:  make(map[string]*pentabarf.Person),
+		byName:  make(map[string]*pentabarf.Person),
+		byEvent: make(map[string][]*pentabarf.Person),
+	}
+	for _, p := range persons {
+		if _, found := m.bySlug[names.Slug(p.Name)]; !found {
+			m.bySlug[names.Slug(p.Name)] = p
+		}
+		if _, found := m.byName[names.Normalize(p.Name)]; !found {
+			m.byName[names.Normalize(p.Name)] = p
+		}
+	}
+	for _, e := range schedule.GetAllEvents() {
+		if e.Slug != "" {
+			m.byEvent[e.Slug] = e.Persons
+		}
+		// the speakers of pretalx list the codes of their submissions
+		if e.Code != "" {
+			m.byEvent[e.Code] = e.Persons
+		}
+	}
+	return m
+}
+
+// match returns the person of the speaker, trying the events of the speaker, the slug,
+// the normalized name and at last the similar names
+func (m *personMatcher) match(s web.Speaker) (personMatch, bool) {
+	if pm, found := m.matchEvents(s); found {
+		return pm, true
+	}
+	if p, found := m.bySlug[s.Slug]; found && s.Slug != "" {
+		return personMatch{person: p, method: MatchSlug, confidence: 0.95}, true
+	}
+	if p, found := m.byName[names.Normalize(s.Name)]; found {
+		return personMatch{person: p, method: MatchName, confidence: 0.9}, true
+	}
+
+	p, similarity := mostSimilar(s.Name, m.persons)
+	if similarity >= minFuzzySimilarity {
+		return personMatch{person: p, method: MatchFuzzy, confidence: 0.9 * similarity}, true
+	}
+	return personMatch{}, false
+}
+
+// matchEvents returns the only person of all the events listed in the profile page,
+// or the one with the most similar name if they are more than one
+func (m *personMatcher) matchEvents(s web.Speaker) (personMatch, bool) {
+	var candidates []*pentabarf.Person
+	for _, slug := range s.EventSlugs {
+		persons, found := m.byEvent[slug]
+		if !found {
+			continue
+		}
+		if candidates == nil {
+			candidates = persons
+			continue
+		}
+		candidates = intersect(candidates, persons)
+	}
+
+	switch {
+	case len(candidates) == 0:
+		return personMatch{}, false
+	case len(candidates) == 1:
+		return personMatch{person: candidates[0], method: MatchEvent, confidence: 1}, true
+	}
+
+	for _, p := range candidates {
+		if names.Slug(p.Name) == s.Slug || names.Normalize(p.Name) == names.Normalize(s.Name) {
+			return personMatch{person: p, method: MatchEvent, confidence: 1}, true
+		}
+	}
+	p, similarity := mostSimilar(s.Name, candidates)
+	if similarity >= minEventSimilarity {
+		return personMatch{person: p, method: MatchEvent, confidence: 0.8 * similarity}, true
+	}
+	return personMatch{}, false
+}
+
+func intersect(a, b []*pentabarf.Person) []*pentabarf.Person {
+	persons := make([]*pentabarf.Person, 0)
+	for _, pa := range a {
+		for _, pb := range b {
+			if pa.ID == pb.ID {
+				persons = append(persons, pa)
+				break
+			}
+		}
+	}
+	return persons
+}
+
+func mostSimilar(name string, persons []*pentabarf.Person) (*pentabarf.Person, float64) {
+	var best *pentabarf.Person
+	var bestSimilarity float64
+	for _, p := range persons {
+		if similarity := names.Similarity(name, p.Name); similarity > bestSimilarity {
+			best, bestSimilarity = p, similarity
+		}
+	}
+	return best, bestSimilarity
+}
@@ -0,0 +1,118 @@
+package indexer
+
+import (
+	"os"
+	"testing"
+
+	"github.com/enrichman/api-fosdem/pentabarf"
+	"github.com/enrichman/api-fosdem/web"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersonMatcher(t *testing.T) {
+	f, err := os.Open("../pentabarf/pentabarf_test.xml")
+	assert.Nil(t, err)
+	defer f.Close()
+	schedule, err := pentabarf.Parse(f)
+	assert.Nil(t, err)
+
+	// the event 234 of Mario Rossi, as submitted in pretalx
+	schedule.Days[0].Rooms[1].Events[0].Code = "ABC12"
+	matcher := newPersonMatcher(schedule)
+
+	tt := []struct {
+		name       string
+		speaker    web.Speaker
+		found      bool
+		personID   int
+		method     string
+		confidence float64
+	}{
+		{
+			name:       "only person of the events",
+			speaker:    web.Speaker{Slug: "mrossi", Name: "M. R.", EventSlugs: []string{"event_123_slug", "event_234_slug"}},
+			found:      true,
+			personID:   1,
+			method:     MatchEvent,
+			confidence: 1,
+		},
+		{
+			name:       "person of the event with the same name",
+			speaker:    web.Speaker{Slug: "paolo_bianchi", Name: "Paolo Bianchi", EventSlugs: []string{"event_123_slug"}},
+			found:      true,
+			personID:   2,
+			method:     MatchEvent,
+			confidence: 1,
+		},
+		{
+			name:       "submission of pretalx",
+			speaker:    web.Speaker{Slug: "mrossi", Name: "M. R.", EventSlugs: []string{"ABC12"}},
+			found:      true,
+			personID:   1,
+			method:     MatchEvent,
+			confidence: 1,
+		},
+		{
+			name:       "slug",
+			speaker:    web.Speaker{Slug: "mario_rossi", Name: "Mario  Rossi"},
+			found:      true,
+			personID:   1,
+			method:     MatchSlug,
+			confidence: 0.95,
+		},
+		{
+			name:       "normalized name",
+			speaker:    web.Speaker{Slug: "paolo", Name: "  paolo BIANCHI"},
+			found:      true,
+			personID:   2,
+			method:     MatchName,
+			confidence: 0.9,
+		},
+		{
+			name:     "similar name",
+			speaker:  web.Speaker{Slug: "paolo", Name: "Paolo Bianci"},
+			found:    true,
+			personID: 2,
+			method:   MatchFuzzy,
+		},
+		{
+			name:    "unknown",
+			speaker: web.Speaker{Slug: "unknown", Name: "Unknown Speaker", EventSlugs: []string{"unknown_event"}},
+			found:   false,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			m, found := matcher.match(tc.speaker)
+			assert.Equal(t, tc.found, found)
+			if !tc.found {
+				return
+			}
+			assert.Equal(t, tc.personID, m.person.ID)
+			assert.Equal(t, tc.method, m.method)
+			if tc.method == MatchFuzzy {
+				assert.True(t, m.confidence > 0 && m.confidence < reviewConfidence)
+			} else {
+				assert.Equal(t, tc.confidence, m.confidence)
+			}
+		})
+	}
+}
+
+func TestPersonMatcherSameName(t *testing.T) {
+	schedule := &pentabarf.Schedule{Days: []*pentabarf.Day{{Rooms: []*pentabarf.Room{{Events: []*pentabarf.Event{
+		{ID: 1, Slug: "event_1", Persons: []*pentabarf.Person{{ID: 7, Name: "Mario Rossi"}}},
+		{ID: 2, Slug: "event_2", Persons: []*pentabarf.Person{{ID: 3, Name: "Mario Rossi"}}},
+	}}}}}}
+	matcher := newPersonMatcher(schedule)
+
+	// both the persons are known, the lowest ID wins the name
+	assert.Len(t, matcher.persons, 2)
+	m, found := matcher.match(web.Speaker{Slug: "mario_rossi", Name: "Mario Rossi"})
+	assert.True(t, found)
+	assert.Equal(t, 3, m.person.ID)
+	m, found = matcher.match(web.Speaker{Slug: "mario_rossi", Name: "Mario Rossi", EventSlugs: []string{"event_1"}})
+	assert.True(t, found)
+	assert.Equal(t, 7, m.person.ID)
+}
@@ -0,0 +1,34 @@
+package indexer
+
+import (
+	"github.com/enrichman/api-fosdem/store"
+	"github.com/enrichman/api-fosdem/web"
+)
+
+type speakerPageFinder interface {
+	FindSpeakerByProfilePage(profilePage string) (*store.Speaker, error)
+}
+
+// PageStates returns the state of the profile pages of the indexed speakers,
+// used by the incremental indexing to skip the unchanged pages
+type PageStates struct {
+	finder speakerPageFinder
+}
+
+// NewPageStates returns a PageStates reading the states from the stored speakers
+func NewPageStates(finder speakerPageFinder) *PageStates {
+	return &PageStates{finder}
+}
+
+// GetPageState returns the state of the profile page, if it was already indexed
+func (ps *PageStates) GetPageState(profilePage string) (web.PageState, bool) {
+	s, err := ps.finder.FindSpeakerByProfilePage(profilePage)
+	if err != nil {
+		return web.PageState{}, false
+	}
+	return web.PageState{
+		ETag:         s.PageETag,
+		LastModified: s.PageLastModified,
+		Hash:         s.PageHash,
+	}, true
+}
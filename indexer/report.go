@@ -0,0 +1,664 @@
+package indexer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/enrichman/api-fosdem/retry"
+	"github.com/enrichman/api-fosdem/store"
+	"github.com/enrichman/api-fosdem/web"
+)
+
+// maxReports is the number of past reports returned by GetJobs
+const maxReports = 20
+
+// the statuses of an indexing, and of the years indexed by it
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+var (
+	// ErrJobRunning is returned when a reindex is requested while another one is running
+	ErrJobRunning = errors.New("reindex already running")
+	// ErrJobNotFound is returned when the requested job does not exist
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobNotRunning is returned when cancelling a job already ended
+	ErrJobNotRunning = errors.New("job not running")
+)
+
+type reportStore interface {
+	SaveReport(r store.Report) error
+	FindReport(id string) (*store.Report, error)
+	FindReports(limit int) ([]store.Report, error)
+}
+
+// IndexReport is the outcome of an indexing, updated while the indexing is running
+type IndexReport struct {
+	ID        string        `json:"id"`
+	Status    string        `json:"status"`
+	StartedAt time.Time     `json:"started_at"`
+	EndedAt   *time.Time    `json:"ended_at,omitempty"`
+	Saved     int           `json:"saved"`
+	Skipped   int           `json:"skipped"`
+	Unchanged int           `json:"unchanged"`
+	Failed    int           `json:"failed"`
+	Years     []*YearReport `json:"years"`
+	Errors    []string      `json:"errors"`
+	// Attempts are the requests to the FOSDEM website that were retried
+	Attempts       []Attempt       `json:"attempts"`
+	BreakerChanges []BreakerChange `json:"breaker_changes"`
+}
+
+// YearReport is the outcome of the indexing of a year
+type YearReport struct {
+	Year              int      `json:"year"`
+	Status            string   `json:"status"`
+	Events            int      `json:"events"`
+	Speakers          int      `json:"speakers"`
+	Saved             int      `json:"saved"`
+	Skipped           int      `json:"skipped"`
+	Unchanged         int      `json:"unchanged"`
+	Failed            int      `json:"failed"`
+	CurrentSpeaker    string   `json:"current_speaker,omitempty"`
+	Error             string   `json:"error,omitempty"`
+	UnmatchedSpeakers []string `json:"unmatched_speakers"`
+	// UncertainMatches are the matches with a low confidence, to review
+	UncertainMatches []SpeakerMatch `json:"uncertain_matches"`
+	// Conflicts are the speakers matched to a person already matched to another speaker
+	Conflicts    []SpeakerConflict `json:"conflicts"`
+	PageFailures []PageFailure     `json:"page_failures"`
+	StoreErrors  []string          `json:"store_errors"`
+}
+
+// PageFailure is a page that could not be fetched or parsed
+type PageFailure struct {
+	URL   string `json:"url,omitempty"`
+	Error string `json:"error"`
+}
+
+// SpeakerMatch is a speaker of the website matched to a person of the schedule
+type SpeakerMatch struct {
+	Name       string  `json:"name"`
+	PersonID   int     `json:"person_id"`
+	PersonName string  `json:"person_name"`
+	Method     string  `json:"method"`
+	Confidence float64 `json:"confidence"`
+}
+
+// SpeakerConflict is a speaker not saved, as its person was matched to another speaker with a higher confidence
+type SpeakerConflict struct {
+	Name      string `json:"name"`
+	PersonID  int    `json:"person_id"`
+	MatchedTo string `json:"matched_to"`
+}
+
+// Attempt is an attempt of a retried request
+type Attempt struct {
+	URL    string    `json:"url"`
+	Number int       `json:"attempt"`
+	Error  string    `json:"error,omitempty"`
+	Wait   string    `json:"wait,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// BreakerChange is a change of the state of the circuit breaker, "open" when the requests are paused
+type BreakerChange struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+}
+
+func newIndexReport() *IndexReport {
+	return &IndexReport{
+		ID:        newReportID(),
+		Status:    StatusRunning,
+		StartedAt: time.Now(),
+		Years:     make([]*YearReport, 0),
+		Errors:    make([]string, 0),
+
+		Attempts:       make([]Attempt, 0),
+		BreakerChanges: make([]BreakerChange, 0),
+	}
+}
+
+// copy returns a deep copy of the report, that can be read while the indexing is running
+func (r *IndexReport) copy() *IndexReport {
+	c := *r
+	c.Years = make([]*YearReport, 0, len(r.Years))
+	for _, y := range r.Years {
+		yc := *y
+		yc.UnmatchedSpeakers = append([]string{}, y.UnmatchedSpeakers...)
+		yc.UncertainMatches = append([]SpeakerMatch{}, y.UncertainMatches...)
+		yc.Conflicts = append([]SpeakerConflict{}, y.Conflicts...)
+		yc.PageFailures = append([]PageFailure{}, y.PageFailures...)
+		yc.StoreErrors = append([]string{}, y.StoreErrors...)
+		c.Years = append(c.Years, &yc)
+	}
+	c.Errors = append([]string{}, r.Errors...)
+	c.Attempts = append([]Attempt{}, r.Attempts...)
+	c.BreakerChanges = append([]BreakerChange{}, r.BreakerChanges...)
+	return &c
+}
+
+// progress records the progress of a running indexing in its report
+type progress struct {
+	mu     *sync.Mutex
+	report *IndexReport
+}
+
+func newProgress(mu *sync.Mutex, report *IndexReport) *progress {
+	return &progress{mu: mu, report: report}
+}
+
+func (p *progress) update(year int, f func(y *YearReport)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, y := range p.report.Years {
+		if y.Year == year {
+			f(y)
+			return
+		}
+	}
+	y := &YearReport{
+		Year:              year,
+		Status:            StatusPending,
+		UnmatchedSpeakers: make([]string, 0),
+		UncertainMatches:  make([]SpeakerMatch, 0),
+		Conflicts:         make([]SpeakerConflict, 0),
+		PageFailures:      make([]PageFailure, 0),
+		StoreErrors:       make([]string, 0),
+	}
+	p.report.Years = append(p.report.Years, y)
+	f(y)
+}
+
+func (p *progress) yearsFound(years []int) {
+	for _, year := range years {
+		p.update(year, func(y *YearReport) {})
+	}
+}
+
+func (p *progress) yearStarted(year int) {
+	p.update(year, func(y *YearReport) { y.Status = StatusRunning })
+}
+
+func (p *progress) yearEnded(year int, err error) {
+	p.update(year, func(y *YearReport) {
+		y.Status = StatusCompleted
+		y.CurrentSpeaker = ""
+		if cancelled(err) {
+			y.Status = StatusCancelled
+		} else if err != nil {
+			y.Status = StatusFailed
+			y.Error = err.Error()
+		}
+	})
+}
+
+func (p *progress) eventsSaved(year, count int) {
+	p.update(year, func(y *YearReport) { y.Events = count })
+}
+
+func (p *progress) speakerStarted(year int, name string) {
+	p.update(year, func(y *YearReport) {
+		y.Speakers++
+		y.CurrentSpeaker = name
+	})
+}
+
+func (p *progress) speakerSaved(year int) {
+	p.update(year, func(y *YearReport) {
+		y.Saved++
+		p.report.Saved++
+	})
+}
+
+func (p *progress) speakerUnmatched(year int, name string) {
+	p.update(year, func(y *YearReport) {
+		y.Skipped++
+		y.UnmatchedSpeakers = append(y.UnmatchedSpeakers, name)
+		p.report.Skipped++
+	})
+}
+
+// speakerConflicted records a speaker not saved, as its person was matched to another speaker
+func (p *progress) speakerConflicted(year int, name string, personID int, matchedTo string) {
+	p.update(year, func(y *YearReport) {
+		y.Skipped++
+		y.Conflicts = append(y.Conflicts, SpeakerConflict{Name: name, PersonID: personID, MatchedTo: matchedTo})
+		p.report.Skipped++
+	})
+}
+
+// speakerMatched records the matches with a low confidence, to review them
+func (p *progress) speakerMatched(year int, name string, m personMatch) {
+	if m.confidence >= reviewConfidence {
+		return
+	}
+	p.update(year, func(y *YearReport) {
+		y.UncertainMatches = append(y.UncertainMatches, SpeakerMatch{
+			Name:       name,
+			PersonID:   m.person.ID,
+			PersonName: m.person.Name,
+			Method:     m.method,
+			Confidence: m.confidence,
+		})
+	})
+}
+
+func (p *progress) speakerUnchanged(year int) {
+	p.update(year, func(y *YearReport) {
+		y.Unchanged++
+		p.report.Unchanged++
+	})
+}
+
+func (p *progress) pageFailed(year int, err error) {
+	failure := PageFailure{Error: err.Error()}
+	if pageErr, ok := err.(*web.PageError); ok {
+		failure = PageFailure{URL: pageErr.URL, Error: pageErr.Err.Error()}
+	}
+	p.update(year, func(y *YearReport) {
+		y.Failed++
+		y.PageFailures = append(y.PageFailures, failure)
+		p.report.Failed++
+	})
+}
+
+// storeFailed records an error of the store. If a speaker was being saved it is counted as failed.
+func (p *progress) storeFailed(year int, err error, speaker bool) {
+	p.update(year, func(y *YearReport) {
+		if speaker {
+			y.Failed++
+			p.report.Failed++
+		}
+		y.StoreErrors = append(y.StoreErrors, err.Error())
+	})
+}
+
+func (p *progress) retried(a retry.Attempt) {
+	attempt := Attempt{URL: a.Target, Number: a.Number, At: time.Now()}
+	if a.Err != nil {
+		attempt.Error = a.Err.Error()
+	}
+	if a.Wait > 0 {
+		attempt.Wait = a.Wait.String()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.report.Attempts = append(p.report.Attempts, attempt)
+}
+
+func (p *progress) breakerChanged(from, to retry.State) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.report.BreakerChanges = append(p.report.BreakerChanges, BreakerChange{
+		From: from.String(),
+		To:   to.String(),
+		At:   time.Now(),
+	})
+}
+
+func (p *progress) error(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.report.Errors = append(p.report.Errors, err.Error())
+}
+
+// end marks the end of the indexing
+func (p *progress) end(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	p.report.EndedAt = &now
+	p.report.Status = StatusCompleted
+	if cancelled(err) {
+		p.report.Status = StatusCancelled
+	} else if err != nil {
+		p.report.Status = StatusFailed
+		p.report.Errors = append(p.report.Errors, err.Error())
+	}
+}
+
+// cancelled reports whether the error is context.Canceled, also when wrapped by the HTTP client
+func cancelled(err error) bool {
+	for {
+		switch e := err.(type) {
+		case *url.Error:
+			err = e.Err
+		case *net.OpError:
+			err = e.Err
+		default:
+			return err == context.Canceled
+		}
+	}
+}
+
+// StartJob starts a reindex in background, returning ErrJobRunning (and the running job) if another one is running
+func (fi *RemoteIndexer) StartJob() (*IndexReport, error) {
+	report, ctx, end, err := fi.startJob(context.Background())
+	if err != nil {
+		return report, err
+	}
+	// the report is copied before the indexing can update it
+	started := report.copy()
+
+	go func() {
+		defer end()
+		fi.runIndex(ctx, report, fi.index)
+	}()
+
+	return started, nil
+}
+
+// startJob sets a new job as the running one, returning ErrJobRunning (and the running job) if another one is running.
+// It returns the report of the new job, the context cancelled by CancelJob and Shutdown, and the function
+// to call when the job ended.
+func (fi *RemoteIndexer) startJob(ctx context.Context) (*IndexReport, context.Context, func(), error) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	if fi.running != nil {
+		return fi.running.copy(), nil, nil, ErrJobRunning
+	}
+
+	report := newIndexReport()
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	fi.running, fi.cancel, fi.done = report, cancel, done
+
+	end := func() {
+		fi.mu.Lock()
+		fi.running, fi.cancel, fi.done = nil, nil, nil
+		fi.mu.Unlock()
+
+		cancel()
+		close(done)
+	}
+	return report, ctx, end, nil
+}
+
+// CancelJob stops the running job with the passed ID. The job is cancelled asynchronously,
+// its report is saved with the cancelled status when the requests in flight are stopped.
+func (fi *RemoteIndexer) CancelJob(id string) (*IndexReport, error) {
+	fi.mu.Lock()
+	if fi.running != nil && fi.running.ID == id {
+		defer fi.mu.Unlock()
+		fi.cancel()
+		return fi.running.copy(), nil
+	}
+	fi.mu.Unlock()
+
+	job, err := fi.GetJob(id)
+	if err != nil {
+		return nil, err
+	}
+	return job, ErrJobNotRunning
+}
+
+// Shutdown cancels the running job, waiting until its report is saved or the context is done
+func (fi *RemoteIndexer) Shutdown(ctx context.Context) error {
+	fi.mu.Lock()
+	done := fi.done
+	if fi.cancel != nil {
+		fi.cancel()
+	}
+	fi.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runIndex runs the indexing of the job, and saves the final report
+func (fi *RemoteIndexer) runIndex(ctx context.Context, report *IndexReport, index func(ctx context.Context, p *progress) error) error {
+	p := newProgress(&fi.mu, report)
+	fi.observe(p)
+	err := index(ctx, p)
+	fi.observe(nil)
+	p.end(err)
+
+	fi.saveReport(report)
+	return err
+}
+
+// jobFailed records a job that could not start, as failed with the error
+func (fi *RemoteIndexer) jobFailed(err error) {
+	report := newIndexReport()
+	newProgress(&fi.mu, report).end(err)
+	fi.saveReport(report)
+}
+
+// saveReport saves the final report. If it can't be saved the report is kept in memory
+// with the error, to be still returned with the jobs.
+func (fi *RemoteIndexer) saveReport(report *IndexReport) {
+	fi.mu.Lock()
+	storeReport := convertReport(report)
+	fi.mu.Unlock()
+
+	err := fi.reportStore.SaveReport(storeReport)
+	if err == nil {
+		return
+	}
+
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	report.Errors = append(report.Errors, "error saving report: "+err.Error())
+	fi.unsaved = append([]*IndexReport{report.copy()}, fi.unsaved...)
+	if len(fi.unsaved) > maxReports {
+		fi.unsaved = fi.unsaved[:maxReports]
+	}
+}
+
+// observe sets the progress where the retries and the changes of the circuit breaker are recorded
+func (fi *RemoteIndexer) observe(p *progress) {
+	fi.observedMu.Lock()
+	defer fi.observedMu.Unlock()
+	fi.observed = p
+}
+
+func (fi *RemoteIndexer) observedProgress() *progress {
+	fi.observedMu.Lock()
+	defer fi.observedMu.Unlock()
+	return fi.observed
+}
+
+// Retried records a retried request in the report of the running indexing, it implements retry.Observer
+func (fi *RemoteIndexer) Retried(a retry.Attempt) {
+	if p := fi.observedProgress(); p != nil {
+		p.retried(a)
+	}
+}
+
+// StateChanged records a change of the circuit breaker in the report of the running indexing,
+// it implements retry.Observer
+func (fi *RemoteIndexer) StateChanged(from, to retry.State) {
+	if p := fi.observedProgress(); p != nil {
+		p.breakerChanged(from, to)
+	}
+}
+
+// GetJob returns the report of the job with the passed ID
+func (fi *RemoteIndexer) GetJob(id string) (*IndexReport, error) {
+	fi.mu.Lock()
+	if fi.running != nil && fi.running.ID == id {
+		defer fi.mu.Unlock()
+		return fi.running.copy(), nil
+	}
+	for _, r := range fi.unsaved {
+		if r.ID == id {
+			defer fi.mu.Unlock()
+			return r.copy(), nil
+		}
+	}
+	fi.mu.Unlock()
+
+	r, err := fi.reportStore.FindReport(id)
+	if err == store.ErrNotFound {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return convertStoreReport(*r), nil
+}
+
+// GetJobs returns the reports of the running job and of the last ones, the most recent first.
+// The reports that could not be saved are returned with the saved ones.
+func (fi *RemoteIndexer) GetJobs() ([]*IndexReport, error) {
+	reports := make([]*IndexReport, 0)
+
+	fi.mu.Lock()
+	if fi.running != nil {
+		reports = append(reports, fi.running.copy())
+	}
+	for _, r := range fi.unsaved {
+		reports = append(reports, r.copy())
+	}
+	fi.mu.Unlock()
+
+	storeReports, err := fi.reportStore.FindReports(maxReports)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range storeReports {
+		reports = append(reports, convertStoreReport(r))
+	}
+
+	sort.SliceStable(reports, func(i, j int) bool { return reports[i].StartedAt.After(reports[j].StartedAt) })
+	return reports, nil
+}
+
+func newReportID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+func convertReport(r *IndexReport) store.Report {
+	report := store.Report{
+		ID:        r.ID,
+		Status:    r.Status,
+		StartedAt: r.StartedAt,
+		EndedAt:   r.EndedAt,
+		Saved:     r.Saved,
+		Skipped:   r.Skipped,
+		Unchanged: r.Unchanged,
+		Failed:    r.Failed,
+		Years:     make([]store.YearReport, 0),
+		Errors:    append([]string{}, r.Errors...),
+
+		Attempts:       make([]store.Attempt, 0),
+		BreakerChanges: make([]store.BreakerChange, 0),
+	}
+	for _, y := range r.Years {
+		yr := store.YearReport{
+			Year:              y.Year,
+			Status:            y.Status,
+			Events:            y.Events,
+			Speakers:          y.Speakers,
+			Saved:             y.Saved,
+			Skipped:           y.Skipped,
+			Unchanged:         y.Unchanged,
+			Failed:            y.Failed,
+			Error:             y.Error,
+			UnmatchedSpeakers: append([]string{}, y.UnmatchedSpeakers...),
+			UncertainMatches:  make([]store.SpeakerMatch, 0),
+			Conflicts:         make([]store.SpeakerConflict, 0),
+			PageFailures:      make([]store.PageFailure, 0),
+			StoreErrors:       append([]string{}, y.StoreErrors...),
+		}
+		for _, c := range y.Conflicts {
+			yr.Conflicts = append(yr.Conflicts, store.SpeakerConflict(c))
+		}
+		for _, f := range y.PageFailures {
+			yr.PageFailures = append(yr.PageFailures, store.PageFailure{URL: f.URL, Error: f.Error})
+		}
+		for _, m := range y.UncertainMatches {
+			yr.UncertainMatches = append(yr.UncertainMatches, store.SpeakerMatch(m))
+		}
+		report.Years = append(report.Years, yr)
+	}
+	for _, a := range r.Attempts {
+		report.Attempts = append(report.Attempts, store.Attempt{URL: a.URL, Number: a.Number, Error: a.Error, Wait: a.Wait, At: a.At})
+	}
+	for _, c := range r.BreakerChanges {
+		report.BreakerChanges = append(report.BreakerChanges, store.BreakerChange{From: c.From, To: c.To, At: c.At})
+	}
+	return report
+}
+
+func convertStoreReport(r store.Report) *IndexReport {
+	report := &IndexReport{
+		ID:        r.ID,
+		Status:    r.Status,
+		StartedAt: r.StartedAt,
+		EndedAt:   r.EndedAt,
+		Saved:     r.Saved,
+		Skipped:   r.Skipped,
+		Unchanged: r.Unchanged,
+		Failed:    r.Failed,
+		Years:     make([]*YearReport, 0),
+		Errors:    append([]string{}, r.Errors...),
+
+		Attempts:       make([]Attempt, 0),
+		BreakerChanges: make([]BreakerChange, 0),
+	}
+	for _, y := range r.Years {
+		yr := &YearReport{
+			Year:              y.Year,
+			Status:            y.Status,
+			Events:            y.Events,
+			Speakers:          y.Speakers,
+			Saved:             y.Saved,
+			Skipped:           y.Skipped,
+			Unchanged:         y.Unchanged,
+			Failed:            y.Failed,
+			Error:             y.Error,
+			UnmatchedSpeakers: append([]string{}, y.UnmatchedSpeakers...),
+			UncertainMatches:  make([]SpeakerMatch, 0),
+			Conflicts:         make([]SpeakerConflict, 0),
+			PageFailures:      make([]PageFailure, 0),
+			StoreErrors:       append([]string{}, y.StoreErrors...),
+		}
+		for _, c := range y.Conflicts {
+			yr.Conflicts = append(yr.Conflicts, SpeakerConflict(c))
+		}
+		for _, f := range y.PageFailures {
+			yr.PageFailures = append(yr.PageFailures, PageFailure{URL: f.URL, Error: f.Error})
+		}
+		for _, m := range y.UncertainMatches {
+			yr.UncertainMatches = append(yr.UncertainMatches, SpeakerMatch(m))
+		}
+		report.Years = append(report.Years, yr)
+	}
+	for _, a := range r.Attempts {
+		report.Attempts = append(report.Attempts, Attempt{URL: a.URL, Number: a.Number, Error: a.Error, Wait: a.Wait, At: a.At})
+	}
+	for _, c := range r.BreakerChanges {
+		report.BreakerChanges = append(report.BreakerChanges, BreakerChange{From: c.From, To: c.To, At: c.At})
+	}
+	return report
+}
@@ -0,0 +1,192 @@
+package indexer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/enrichman/api-fosdem/pentabarf"
+	"github.com/enrichman/api-fosdem/store"
+)
+
+type scheduleSaver interface {
+	SaveConference(c store.Conference) error
+	FindConference(year int) (*store.Conference, error)
+	SaveDay(d store.Day) error
+	SaveRoom(r store.Room) error
+	SaveTrack(t store.Track) error
+	SaveEvent(e store.Event) error
+	DeleteEvents(year int, keepIDs []int) error
+	SaveSnapshot(s store.Snapshot) error
+	FindLatestSnapshot(year int) (*store.Snapshot, error)
+}
+
+// saveSchedule saves the conference, the days, the rooms, the tracks and the events of the schedule,
+// returning the number of saved events. When all of them are saved, the events of the year
+// removed from the schedule are deleted. The conference keeps the time of the last change of the schedule.
+func (fi *RemoteIndexer) saveSchedule(year int, schedule *pentabarf.Schedule) (int, error) {
+	if schedule.Conference != nil {
+		conf, err := fi.updatedConference(year, schedule)
+		if err != nil {
+			return 0, err
+		}
+		err = fi.scheduleSaver.SaveConference(conf)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	events := 0
+	eventIDs := make([]int, 0)
+	rooms := make(map[string]bool)
+	tracks := make(map[string]bool)
+
+	for _, d := range schedule.Days {
+		err := fi.scheduleSaver.SaveDay(store.Day{Year: year, Index: d.Index, Date: d.DateStr})
+		if err != nil {
+			return events, err
+		}
+
+		for _, r := range d.Rooms {
+			if !rooms[r.Name] {
+				rooms[r.Name] = true
+				err = fi.scheduleSaver.SaveRoom(store.Room{Year: year, Name: r.Name})
+				if err != nil {
+					return events, err
+				}
+			}
+
+			for _, e := range r.Events {
+				if e.Track != "" && !tracks[e.Track] {
+					tracks[e.Track] = true
+					err = fi.scheduleSaver.SaveTrack(store.Track{Year: year, Name: e.Track})
+					if err != nil {
+						return events, err
+					}
+				}
+
+				err = fi.scheduleSaver.SaveEvent(convertEvent(year, d, e))
+				if err != nil {
+					return events, err
+				}
+				events++
+				eventIDs = append(eventIDs, e.ID)
+			}
+		}
+	}
+
+	return events, fi.scheduleSaver.DeleteEvents(year, eventIDs)
+}
+
+// updatedConference returns the conference of the schedule, updated now if any field of the schedule changed
+func (fi *RemoteIndexer) updatedConference(year int, schedule *pentabarf.Schedule) (store.Conference, error) {
+	conf := convertConference(year, schedule.Conference)
+	hash, err := hashSchedule(year, schedule)
+	if err != nil {
+		return conf, err
+	}
+	conf.ScheduleHash = hash
+	conf.UpdatedAt = time.Now()
+
+	stored, err := fi.scheduleSaver.FindConference(year)
+	if err != nil && err != store.ErrNotFound {
+		return conf, err
+	}
+	if stored != nil && stored.ScheduleHash == hash {
+		conf.UpdatedAt = stored.UpdatedAt
+	}
+	return conf, nil
+}
+
+// hashSchedule returns the hash of the conference and of the events of the schedule, as they are stored
+func hashSchedule(year int, schedule *pentabarf.Schedule) (string, error) {
+	events := make([]store.Event, 0)
+	for _, d := range schedule.Days {
+		for _, e := range d.GetAllEvents() {
+			events = append(events, convertEvent(year, d, e))
+		}
+	}
+	b, err := json.Marshal(struct {
+		Conference store.Conference
+		Events     []store.Event
+	}{convertConference(year, schedule.Conference), events})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// saveSnapshot saves a new version of the events of the year if they changed since the last snapshot
+func (fi *RemoteIndexer) saveSnapshot(year int, schedule *pentabarf.Schedule) error {
+	snapshot := store.Snapshot{
+		Year:      year,
+		Version:   1,
+		CreatedAt: time.Now(),
+		Events:    make([]store.Event, 0),
+	}
+	for _, d := range schedule.Days {
+		for _, e := range d.GetAllEvents() {
+			snapshot.Events = append(snapshot.Events, convertEvent(year, d, e))
+		}
+	}
+
+	latest, err := fi.scheduleSaver.FindLatestSnapshot(year)
+	if err != nil && err != store.ErrNotFound {
+		return err
+	}
+	if latest != nil {
+		changes := pentabarf.Diff(store.BuildSchedule(latest.Events), store.BuildSchedule(snapshot.Events))
+		if changes.IsEmpty() {
+			return nil
+		}
+		snapshot.Version = latest.Version + 1
+	}
+
+	return fi.scheduleSaver.SaveSnapshot(snapshot)
+}
+
+func convertConference(year int, c *pentabarf.Conference) store.Conference {
+	return store.Conference{
+		Year:      year,
+		Title:     c.Title,
+		Subtitle:  c.Subtitle,
+		Venue:     c.Venue,
+		City:      c.City,
+		StartDate: c.StartDate,
+		EndDate:   c.EndDate,
+		Days:      c.Days,
+		DayChange: c.DayChange,
+	}
+}
+
+func convertEvent(year int, d *pentabarf.Day, e *pentabarf.Event) store.Event {
+	event := store.Event{
+		ID:          e.ID,
+		Year:        year,
+		Slug:        e.Slug,
+		Title:       e.Title,
+		Subtitle:    e.Subtitle,
+		Track:       e.Track,
+		Type:        e.Type,
+		Language:    e.Language,
+		Room:        e.Room,
+		Day:         d.Index,
+		Date:        d.DateStr,
+		Start:       e.Start,
+		End:         e.Start.Add(e.Duration),
+		Duration:    e.Duration,
+		Abstract:    e.Abstract,
+		Description: e.Description,
+		Persons:     make([]store.Person, 0),
+		Links:       make([]store.Link, 0),
+	}
+	for _, p := range e.Persons {
+		event.Persons = append(event.Persons, store.Person{ID: p.ID, Name: p.Name})
+	}
+	for _, l := range e.Links {
+		event.Links = append(event.Links, store.Link{URL: l.URL, Title: l.Text})
+	}
+	return event
+}
@@ -0,0 +1,96 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/enrichman/api-fosdem/store"
+)
+
+// conferenceWindow is the time before and after the conference when the schedule changes more often
+const conferenceWindow = 3 * 7 * 24 * time.Hour
+
+type jobStarter interface {
+	StartJob() (*IndexReport, error)
+	jobFailed(err error)
+}
+
+type conferenceFinder interface {
+	FindLatestConference() (*store.Conference, error)
+}
+
+// Scheduler starts a reindex periodically, more often in the weeks around the conference
+type Scheduler struct {
+	jobStarter         jobStarter
+	conferenceFinder   conferenceFinder
+	interval           time.Duration
+	conferenceInterval time.Duration
+
+	now func() time.Time
+	// newTimer returns the channel of a timer and the function stopping it
+	newTimer func(d time.Duration) (<-chan time.Time, func() bool)
+}
+
+// NewScheduler returns a Scheduler reindexing every interval, or every conferenceInterval
+// in the weeks around the start date of the latest conference.
+// The interval must be positive.
+func NewScheduler(
+	jobStarter jobStarter,
+	conferenceFinder conferenceFinder,
+	interval time.Duration,
+	conferenceInterval time.Duration,
+) (*Scheduler, error) {
+	if interval <= 0 {
+		return nil, errors.New("the reindex interval must be positive: " + interval.String())
+	}
+	if conferenceInterval <= 0 || conferenceInterval > interval {
+		conferenceInterval = interval
+	}
+	return &Scheduler{
+		jobStarter:         jobStarter,
+		conferenceFinder:   conferenceFinder,
+		interval:           interval,
+		conferenceInterval: conferenceInterval,
+		now:                time.Now,
+		newTimer: func(d time.Duration) (<-chan time.Time, func() bool) {
+			timer := time.NewTimer(d)
+			return timer.C, timer.Stop
+		},
+	}, nil
+}
+
+// Run starts a reindex at every interval, until the context is done.
+// A reindex is skipped if the previous one is still running.
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		c, stop := s.newTimer(s.nextInterval())
+		select {
+		case <-ctx.Done():
+			stop()
+			return
+		case <-c:
+			_, err := s.jobStarter.StartJob()
+			if err != nil && err != ErrJobRunning {
+				// the reindex that could not start is listed in the jobs as failed
+				s.jobStarter.jobFailed(errors.New("error starting scheduled reindex: " + err.Error()))
+			}
+		}
+	}
+}
+
+// nextInterval returns the time to wait for the next reindex
+func (s *Scheduler) nextInterval() time.Duration {
+	conf, err := s.conferenceFinder.FindLatestConference()
+	if err != nil {
+		return s.interval
+	}
+
+	now := s.now()
+	// the end date is the start of the last day
+	end := conf.EndDate.Add(24 * time.Hour)
+	if now.After(conf.StartDate.Add(-conferenceWindow)) && now.Before(end.Add(conferenceWindow)) {
+		return s.conferenceInterval
+	}
+	return s.interval
+}
@@ -0,0 +1,160 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/enrichman/api-fosdem/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingStarter refuses every other job, with err or ErrJobRunning if nil
+type countingStarter struct {
+	started int32
+	err     error
+	failed  []error
+}
+
+func (s *countingStarter) StartJob() (*IndexReport, error) {
+	if atomic.AddInt32(&s.started, 1)%2 == 0 {
+		if s.err != nil {
+			return nil, s.err
+		}
+		return nil, ErrJobRunning
+	}
+	return newIndexReport(), nil
+}
+
+func (s *countingStarter) jobFailed(err error) {
+	s.failed = append(s.failed, err)
+}
+
+type localConferenceFinder struct {
+	conf *store.Conference
+}
+
+func (f *localConferenceFinder) FindLatestConference() (*store.Conference, error) {
+	if f.conf == nil {
+		return nil, store.ErrNotFound
+	}
+	return f.conf, nil
+}
+
+func TestScheduler_nextInterval(t *testing.T) {
+	conf := &store.Conference{
+		Year:      2018,
+		StartDate: time.Date(2018, time.February, 3, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2018, time.February, 4, 0, 0, 0, 0, time.UTC),
+	}
+
+	tt := []struct {
+		name        string
+		conf        *store.Conference
+		now         time.Time
+		expInterval time.Duration
+	}{
+		{
+			name:        "no conference indexed",
+			now:         time.Date(2018, time.February, 3, 0, 0, 0, 0, time.UTC),
+			expInterval: 24 * time.Hour,
+		},
+		{
+			name:        "far from the conference",
+			conf:        conf,
+			now:         time.Date(2017, time.October, 1, 0, 0, 0, 0, time.UTC),
+			expInterval: 24 * time.Hour,
+		},
+		{
+			name:        "weeks before the conference",
+			conf:        conf,
+			now:         time.Date(2018, time.January, 20, 0, 0, 0, 0, time.UTC),
+			expInterval: time.Hour,
+		},
+		{
+			name:        "during the conference",
+			conf:        conf,
+			now:         time.Date(2018, time.February, 4, 18, 0, 0, 0, time.UTC),
+			expInterval: time.Hour,
+		},
+		{
+			name:        "long after the conference",
+			conf:        conf,
+			now:         time.Date(2018, time.April, 1, 0, 0, 0, 0, time.UTC),
+			expInterval: 24 * time.Hour,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := NewScheduler(&countingStarter{}, &localConferenceFinder{tc.conf}, 24*time.Hour, time.Hour)
+			assert.Nil(t, err)
+			s.now = func() time.Time { return tc.now }
+
+			assert.Equal(t, tc.expInterval, s.nextInterval())
+		})
+	}
+}
+
+func TestNewScheduler(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Hour} {
+		_, err := NewScheduler(&countingStarter{}, &localConferenceFinder{}, interval, time.Hour)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestScheduler_Run(t *testing.T) {
+	tt := []struct {
+		name           string
+		err            error
+		expectedFailed []error
+	}{
+		{name: "job running", err: nil, expectedFailed: nil},
+		{name: "job not started", err: errors.New("store down"), expectedFailed: []error{errors.New("error starting scheduled reindex: store down")}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			starter := &countingStarter{err: tc.err}
+			testSchedulerRun(t, starter)
+
+			// the jobs that could not start are recorded
+			assert.Equal(t, tc.expectedFailed, starter.failed)
+		})
+	}
+}
+
+func testSchedulerRun(t *testing.T, starter *countingStarter) {
+	s, err := NewScheduler(starter, &localConferenceFinder{}, time.Hour, 0)
+	assert.Nil(t, err)
+
+	// the timers fire when the test sends a tick
+	ticks := make(chan time.Time)
+	intervals := make(chan time.Duration, 10)
+	s.newTimer = func(d time.Duration) (<-chan time.Time, func() bool) {
+		intervals <- d
+		return ticks, func() bool { return true }
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	for i := 0; i < 3; i++ {
+		ticks <- time.Now()
+	}
+	cancel()
+	<-done
+
+	// the runs refused do not stop the scheduler
+	assert.Equal(t, int32(3), atomic.LoadInt32(&starter.started))
+	close(intervals)
+	for d := range intervals {
+		assert.Equal(t, time.Hour, d)
+	}
+}
@@ -17,23 +17,59 @@ func MakeReindexerHandler(i indexer) http.Handler {
 	reindexHandler := kithttp.NewServer(
 		makeReindexEndpoint(i),
 		decodeReindex,
-		encodeReindex,
+		encodeResponse,
+	)
+
+	getJobHandler := kithttp.NewServer(
+		makeGetJobEndpoint(i),
+		decodeGetJob,
+		encodeResponse,
+	)
+
+	getJobsHandler := kithttp.NewServer(
+		makeGetJobsEndpoint(i),
+		decodeReindex,
+		encodeResponse,
+	)
+
+	cancelJobHandler := kithttp.NewServer(
+		makeCancelJobEndpoint(i),
+		decodeGetJob,
+		encodeResponse,
 	)
 
 	r.Handle("/api/v1/reindex", reindexHandler).Methods(http.MethodGet)
+	r.Handle("/api/v1/reindex/jobs", getJobsHandler).Methods(http.MethodGet)
+	r.Handle("/api/v1/reindex/jobs/{id}", getJobHandler).Methods(http.MethodGet)
+	r.Handle("/api/v1/reindex/jobs/{id}", cancelJobHandler).Methods(http.MethodDelete)
 
 	return r
 }
 
-func decodeReindex(_ context.Context, r *http.Request) (request interface{}, err error) {
+func decodeToken(r *http.Request) (string, error) {
 	token := r.FormValue("token")
 	if token == "" {
-		return nil, errors.New("missing token")
+		return "", errors.New("missing token")
+	}
+	return token, nil
+}
+
+func decodeReindex(_ context.Context, r *http.Request) (request interface{}, err error) {
+	token, err := decodeToken(r)
+	if err != nil {
+		return nil, err
 	}
 	return reindexRequest{token}, nil
 }
 
-func encodeReindex(_ context.Context, w http.ResponseWriter, res interface{}) error {
-	resp := res.(reindexResponse)
-	return json.NewEncoder(w).Encode(resp)
+func decodeGetJob(_ context.Context, r *http.Request) (request interface{}, err error) {
+	token, err := decodeToken(r)
+	if err != nil {
+		return nil, err
+	}
+	return getJobRequest{token: token, id: mux.Vars(r)["id"]}, nil
+}
+
+func encodeResponse(_ context.Context, w http.ResponseWriter, res interface{}) error {
+	return json.NewEncoder(w).Encode(res)
 }
@@ -1,46 +1,337 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/enrichman/api-fosdem/events"
 	"github.com/enrichman/api-fosdem/indexer"
 	"github.com/enrichman/api-fosdem/pentabarf"
+	"github.com/enrichman/api-fosdem/retry"
+	"github.com/enrichman/api-fosdem/schedule"
 	"github.com/enrichman/api-fosdem/speakers"
 	"github.com/enrichman/api-fosdem/store"
 	"github.com/enrichman/api-fosdem/web"
 )
 
+// the default circuit breaker pauses the requests for a minute after 5 consecutive failures
+const (
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = time.Minute
+)
+
+// defaultBoltPath is the database file of the bolt store, if not configured
+const defaultBoltPath = "api-fosdem.db"
+
+// shutdownTimeout is the time given to the requests and to the running reindex to stop
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	port := os.Getenv("PORT")
 	token := os.Getenv("TOKEN")
-	mongoURI := os.Getenv("MONGO_URI")
-	mongoDB := os.Getenv("MONGO_DB")
+	storeConfig := storeConfig{
+		Type:        os.Getenv("STORE"),
+		File:        os.Getenv("STORE_FILE"),
+		BoltPath:    os.Getenv("BOLT_PATH"),
+		MongoURI:    os.Getenv("MONGO_URI"),
+		MongoDB:     os.Getenv("MONGO_DB"),
+		PostgresURI: os.Getenv("POSTGRES_URI"),
+	}
+	mongoTimeout := os.Getenv("MONGO_TIMEOUT")
+	scheduleCacheDir := os.Getenv("SCHEDULE_CACHE_DIR")
+	scheduleFile := os.Getenv("SCHEDULE_FILE")
+	scheduleDir := os.Getenv("SCHEDULE_DIR")
+	scheduleBaseURL := os.Getenv("SCHEDULE_BASE_URL")
+	scheduleFormats := os.Getenv("SCHEDULE_FORMATS")
+	indexYears := os.Getenv("INDEX_YEARS")
+	reindexInterval := os.Getenv("REINDEX_INTERVAL")
+	reindexConferenceInterval := os.Getenv("REINDEX_CONFERENCE_INTERVAL")
+	incrementalIndex := os.Getenv("INCREMENTAL_INDEX") != "false"
+	scraperWorkers := os.Getenv("SCRAPER_WORKERS")
+	scraperRate := os.Getenv("SCRAPER_RATE")
+	scraperUserAgent := os.Getenv("SCRAPER_USER_AGENT")
+	pretalxOptions := web.PretalxOptions{
+		BaseURL: os.Getenv("PRETALX_BASE_URL"),
+		Token:   os.Getenv("PRETALX_TOKEN"),
+		Event:   os.Getenv("PRETALX_EVENT"),
+	}
+	pretalxYears := os.Getenv("PRETALX_YEARS")
+	retryMaxAttempts := os.Getenv("RETRY_MAX_ATTEMPTS")
+	breakerThreshold := os.Getenv("BREAKER_THRESHOLD")
+	breakerCooldown := os.Getenv("BREAKER_COOLDOWN")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	mongoStore, err := store.NewMongoStore(mongoURI, mongoDB)
+	var err error
+	if mongoTimeout != "" {
+		storeConfig.MongoTimeout, err = time.ParseDuration(mongoTimeout)
+		if err != nil {
+			panic(err)
+		}
+	}
+	dataStore, err := openStore(storeConfig)
 	if err != nil {
 		panic(err)
 	}
+	// a dataset loaded from a file is read-only, so it is never reindexed
+	readOnly := storeConfig.File != ""
+
+	retryPolicy := retry.DefaultPolicy()
+	if retryMaxAttempts != "" {
+		retryPolicy.MaxAttempts, err = strconv.Atoi(retryMaxAttempts)
+		if err != nil {
+			panic(err)
+		}
+	}
+	threshold, cooldown := defaultBreakerThreshold, defaultBreakerCooldown
+	if breakerThreshold != "" {
+		threshold, err = strconv.Atoi(breakerThreshold)
+		if err != nil {
+			panic(err)
+		}
+	}
+	if breakerCooldown != "" {
+		cooldown, err = time.ParseDuration(breakerCooldown)
+		if err != nil {
+			panic(err)
+		}
+	}
+	// the schedules and the speakers are fetched from the same website, so they share the breaker
+	retrier := retry.New(retryPolicy, retry.NewBreaker(threshold, cooldown))
+
+	var scheduleSource pentabarf.ScheduleSource
+	switch {
+	case scheduleFile != "":
+		scheduleSource = pentabarf.NewFileSource(scheduleFile)
+	case scheduleDir != "":
+		scheduleSource = pentabarf.NewDirSource(scheduleDir)
+	default:
+		httpSource := pentabarf.NewHTTPSource(scheduleBaseURL, nil, retrier)
+		if scheduleFormats != "" {
+			httpSource.Formats, err = parseFormats(scheduleFormats)
+			if err != nil {
+				panic(err)
+			}
+		}
+		scheduleSource = httpSource
+	}
+
+	scraperOptions := web.DefaultOptions()
+	if scraperWorkers != "" {
+		scraperOptions.Workers, err = strconv.Atoi(scraperWorkers)
+		if err != nil {
+			panic(err)
+		}
+	}
+	if scraperRate != "" {
+		scraperOptions.RequestsPerSecond, err = strconv.ParseFloat(scraperRate, 64)
+		if err != nil {
+			panic(err)
+		}
+	}
+	if scraperUserAgent != "" {
+		scraperOptions.UserAgent = scraperUserAgent
+	}
+	scraperOptions.Retrier = retrier
+
+	var pageStates web.PageStateGetter
+	scraper := web.NewSpeakerService(scraperOptions)
+	if incrementalIndex {
+		pageStates = indexer.NewPageStates(dataStore)
+		scraper = web.NewIncrementalSpeakerService(pageStates, scraperOptions)
+	}
+
+	// the speakers of the editions on pretalx are read from its API, the others are scraped from the website
+	speakerService := &web.SpeakerSources{Default: scraper}
+	if pretalxOptions.BaseURL != "" {
+		if pretalxYears == "" {
+			panic(errors.New("PRETALX_YEARS is required with PRETALX_BASE_URL"))
+		}
+		first, last, err := parseYearRange(pretalxYears)
+		if err != nil {
+			panic(err)
+		}
+		pretalx := web.NewPretalxSpeakerService(pretalxOptions, pageStates, scraperOptions)
+		speakerService.Years = make(map[int]web.SpeakerSource)
+		for year := first; year <= last; year++ {
+			speakerService.Years[year] = pretalx
+		}
+	}
+
 	remoteIndexer := indexer.NewRemoteIndexer(
 		token,
-		&pentabarf.CachedScheduleService{},
-		mongoStore,
-		web.NewSpeakerService(),
+		pentabarf.NewCachedScheduleService(scheduleSource, scheduleCacheDir),
+		dataStore,
+		dataStore,
+		speakerService,
+		dataStore,
 	)
+	retrier.SetObserver(remoteIndexer)
+
+	if indexYears != "" {
+		remoteIndexer.FirstYear, remoteIndexer.LastYear, err = parseYearRange(indexYears)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	if reindexInterval != "" && !readOnly {
+		interval, err := time.ParseDuration(reindexInterval)
+		if err != nil {
+			panic(err)
+		}
+		var conferenceInterval time.Duration
+		if reindexConferenceInterval != "" {
+			conferenceInterval, err = time.ParseDuration(reindexConferenceInterval)
+			if err != nil {
+				panic(err)
+			}
+		}
+		scheduler, err := indexer.NewScheduler(remoteIndexer, dataStore, interval, conferenceInterval)
+		if err != nil {
+			panic(err)
+		}
+		go scheduler.Run(ctx)
+	}
 
 	mux := http.NewServeMux()
-	mux.Handle("/api/v1/reindex", indexer.MakeReindexerHandler(remoteIndexer))
-	mux.Handle("/api/v1/", speakers.MakeSpeakersHandler(speakers.NewService(mongoStore)))
+	if !readOnly {
+		reindexerHandler := indexer.MakeReindexerHandler(remoteIndexer)
+		mux.Handle("/api/v1/reindex", reindexerHandler)
+		mux.Handle("/api/v1/reindex/", reindexerHandler)
+	}
+	eventsHandler := events.MakeEventsHandler(events.NewService(dataStore))
+	mux.Handle("/api/v1/events", eventsHandler)
+	mux.Handle("/api/v1/events/", eventsHandler)
+	mux.Handle("/api/v1/events.ics", eventsHandler)
+	mux.Handle("/api/v1/schedule/", schedule.MakeScheduleHandler(schedule.NewService(dataStore)))
+	mux.Handle("/api/v1/", speakers.MakeSpeakersHandler(speakers.NewService(dataStore)))
 	http.Handle("/", mux)
 
 	fmt.Println("listening...", port)
 
 	srv := http.Server{Addr: ":" + port}
-	err = srv.ListenAndServe()
-	if err != nil {
-		panic(err)
+	go func() {
+		err := srv.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			panic(err)
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+
+	// stop the scheduler, the requests and the running reindex, saving its report
+	cancel()
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		fmt.Println("error shutting down the server: " + err.Error())
+	}
+	if err := remoteIndexer.Shutdown(shutdownCtx); err != nil {
+		fmt.Println("error shutting down the indexer: " + err.Error())
+	}
+	if closer, ok := dataStore.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			fmt.Println("error closing the store: " + err.Error())
+		}
 	}
 
 	fmt.Println("closed.")
 }
+
+// storeConfig contains the configuration of all the stores, only the one of the Type is used
+type storeConfig struct {
+	Type         string
+	File         string
+	BoltPath     string
+	MongoURI     string
+	MongoDB      string
+	MongoTimeout time.Duration
+	PostgresURI  string
+}
+
+// openStore returns the store of the configured type: "mongo" (the default), "postgres", "bolt" or "memory".
+// If a file is configured the dataset is loaded from it in a read-only MemoryStore.
+func openStore(c storeConfig) (store.Store, error) {
+	if c.File != "" {
+		f, err := os.Open(c.File)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return store.LoadMemoryStore(f)
+	}
+
+	switch c.Type {
+	case "", "mongo":
+		return store.NewMongoStore(c.MongoURI, c.MongoDB, c.MongoTimeout)
+	case "postgres":
+		return store.NewPostgresStore(c.PostgresURI)
+	case "bolt":
+		if c.BoltPath == "" {
+			c.BoltPath = defaultBoltPath
+		}
+		return store.NewBoltStore(c.BoltPath)
+	case "memory":
+		return store.NewMemoryStore(), nil
+	}
+	return nil, errors.New("unknown store: " + c.Type)
+}
+
+// parseYearRange parses a range of years in the format "2013-2018", or a single year "2018"
+func parseYearRange(str string) (int, int, error) {
+	bounds := strings.Split(str, "-")
+	if len(bounds) == 1 {
+		bounds = append(bounds, bounds[0])
+	}
+	if len(bounds) != 2 {
+		return 0, 0, errors.New("wrong year range: " + str)
+	}
+	first, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	if err != nil {
+		return 0, 0, err
+	}
+	last, err := strconv.Atoi(strings.TrimSpace(bounds[1]))
+	if err != nil {
+		return 0, 0, err
+	}
+	if first > last {
+		return 0, 0, errors.New("wrong year range: " + str)
+	}
+	return first, last, nil
+}
+
+// parseFormats parses the formats of the schedules of the years, in the format "2013-2023:xml,2024-2025:json"
+func parseFormats(str string) (map[int]pentabarf.Format, error) {
+	formats := make(map[int]pentabarf.Format)
+	for _, entry := range strings.Split(str, ",") {
+		parts := strings.Split(entry, ":")
+		if len(parts) != 2 {
+			return nil, errors.New("wrong schedule format: " + entry)
+		}
+		format := pentabarf.Format(strings.TrimSpace(parts[1]))
+		if format != pentabarf.FormatXML && format != pentabarf.FormatJSON {
+			return nil, errors.New("unknown schedule format: " + string(format))
+		}
+		first, last, err := parseYearRange(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		for year := first; year <= last; year++ {
+			formats[year] = format
+		}
+	}
+	return formats, nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseYearRange(t *testing.T) {
+	tt := []struct {
+		str           string
+		expectedFirst int
+		expectedLast  int
+		expectedErr   bool
+	}{
+		{str: "2013-2018", expectedFirst: 2013, expectedLast: 2018},
+		{str: " 2013 - 2018 ", expectedFirst: 2013, expectedLast: 2018},
+		{str: "2018", expectedFirst: 2018, expectedLast: 2018},
+		{str: "2018-2013", expectedErr: true},
+		{str: "2013-2015-2018", expectedErr: true},
+		{str: "twenty", expectedErr: true},
+		{str: "", expectedErr: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.str, func(t *testing.T) {
+			first, last, err := parseYearRange(tc.str)
+			if tc.expectedErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedFirst, first)
+			assert.Equal(t, tc.expectedLast, last)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+// Package names normalizes the names of the speakers, to compare the names
+// scraped from the website with the ones of the schedule.
+package names
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// foldings are the letters replaced when removing the accents
+var foldings = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'č': "c", 'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ę': "e", 'ě': "e",
+	'ğ': "g", 'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'ı': "i",
+	'ľ': "l", 'ĺ': "l", 'ł': "l", 'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o", 'œ': "oe",
+	'ŕ': "r", 'ř': "r", 'ś': "s", 'š': "s", 'ş': "s", 'ß': "ss", 'ť': "t", 'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u",
+	'ý': "y", 'ÿ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+}
+
+// Fold returns the name lowercase and without accents, both composed and decomposed
+func Fold(name string) string {
+	folded := make([]rune, 0, len(name))
+	for _, r := range strings.ToLower(name) {
+		if unicode.Is(unicode.Mn, r) {
+			// combining marks of the decomposed letters
+			continue
+		}
+		if f, found := foldings[r]; found {
+			folded = append(folded, []rune(f)...)
+			continue
+		}
+		folded = append(folded, r)
+	}
+	return string(folded)
+}
+
+// Normalize returns the name folded, with the punctuation removed and the spaces collapsed,
+// i.e. "  José  O'Brien-Smith" is "jose o brien smith"
+func Normalize(name string) string {
+	return strings.Join(words(name), " ")
+}
+
+// Slug returns the slug of the name as used in the URLs of the website, i.e. "jose_o_brien_smith"
+func Slug(name string) string {
+	return strings.Join(words(name), "_")
+}
+
+func words(name string) []string {
+	return strings.FieldsFunc(Fold(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Similarity returns how much two names are similar, from 0 to 1 if equal once normalized.
+// The order of the words is not relevant, so "Rossi Mario" is equal to "Mario Rossi".
+func Similarity(a, b string) float64 {
+	wa, wb := words(a), words(b)
+	similarity := ratio(strings.Join(wa, " "), strings.Join(wb, " "))
+
+	sort.Strings(wa)
+	sort.Strings(wb)
+	if sorted := ratio(strings.Join(wa, " "), strings.Join(wb, " ")); sorted > similarity {
+		similarity = sorted
+	}
+	return similarity
+}
+
+// ratio returns 1 minus the edit distance of the strings relative to the longest one
+func ratio(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(distance(ra, rb))/float64(longest)
+}
+
+// distance returns the Levenshtein distance of the strings
+func distance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
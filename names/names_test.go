@@ -0,0 +1,50 @@
+package names
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	tt := []struct {
+		name       string
+		expected   string
+		expectSlug string
+	}{
+		{name: "Mario Rossi", expected: "mario rossi", expectSlug: "mario_rossi"},
+		{name: "  Mario   Rossi ", expected: "mario rossi", expectSlug: "mario_rossi"},
+		{name: "José O'Brien-Smith", expected: "jose o brien smith", expectSlug: "jose_o_brien_smith"},
+		// decomposed é
+		{name: "Jose\u0301 Garci\u0301a", expected: "jose garcia", expectSlug: "jose_garcia"},
+		{name: "Łukasz Größ", expected: "lukasz gross", expectSlug: "lukasz_gross"},
+		{name: "", expected: "", expectSlug: ""},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Normalize(tc.name))
+			assert.Equal(t, tc.expectSlug, Slug(tc.name))
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tt := []struct {
+		a, b string
+		min  float64
+		max  float64
+	}{
+		{a: "Mario Rossi", b: "mario  rossi", min: 1, max: 1},
+		{a: "Mario Rossi", b: "Rossi Mario", min: 1, max: 1},
+		{a: "Mario Rossi", b: "Mario Rosi", min: 0.9, max: 0.95},
+		{a: "Mario Rossi", b: "Paolo Bianchi", min: 0, max: 0.4},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.a+"-"+tc.b, func(t *testing.T) {
+			s := Similarity(tc.a, tc.b)
+			assert.True(t, s >= tc.min && s <= tc.max, "similarity %f", s)
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package pentabarf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// CachedScheduleService fetches the schedules of the FOSDEM from a ScheduleSource, caching them by year.
+// It is safe for concurrent use, and concurrent requests of the same year are collapsed in a single fetch.
+// If a directory is provided the raw schedules and their validators are persisted on disk,
+// so they survive to a restart.
+type CachedScheduleService struct {
+	source ScheduleSource
+	dir    string
+
+	mu      sync.Mutex
+	entries map[int]*cacheEntry
+	calls   map[int]*call
+}
+
+// cacheEntry is a cached schedule with the validators used for the conditional requests
+type cacheEntry struct {
+	Validators
+	Format   Format `json:"format,omitempty"`
+	schedule *Schedule
+}
+
+// call is an in-flight fetch of a schedule, done is closed when it ends
+type call struct {
+	done     chan struct{}
+	schedule *Schedule
+	err      error
+}
+
+// NewCachedScheduleService returns a CachedScheduleService fetching the schedules from the source,
+// and persisting them in the dir. If dir is empty the schedules are cached only in memory.
+// A nil source fetches the schedules from https://fosdem.org.
+func NewCachedScheduleService(source ScheduleSource, dir string) *CachedScheduleService {
+	return &CachedScheduleService{source: source, dir: dir}
+}
+
+// GetSchedule returns the schedule of the year, fetching it only if changed since the last request.
+// When the context is done the fetch is cancelled, and the callers waiting for it return.
+func (c *CachedScheduleService) GetSchedule(ctx context.Context, year int) (*Schedule, error) {
+	c.mu.Lock()
+	if c.calls == nil {
+		c.calls = make(map[int]*call)
+	}
+	if c.source == nil {
+		c.source = NewHTTPSource("", nil, nil)
+	}
+	if cl, found := c.calls[year]; found {
+		c.mu.Unlock()
+		select {
+		case <-cl.done:
+			return cl.schedule, cl.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	cl := &call{done: make(chan struct{})}
+	c.calls[year] = cl
+	c.mu.Unlock()
+
+	cl.schedule, cl.err = c.fetch(ctx, year)
+	close(cl.done)
+
+	c.mu.Lock()
+	delete(c.calls, year)
+	c.mu.Unlock()
+
+	return cl.schedule, cl.err
+}
+
+func (c *CachedScheduleService) fetch(ctx context.Context, year int) (*Schedule, error) {
+	entry := c.getEntry(year)
+
+	var validators Validators
+	if entry != nil {
+		validators = entry.Validators
+	}
+
+	raw, validators, err := c.source.Fetch(ctx, year, validators)
+	if err == ErrNotModified && entry != nil {
+		return entry.schedule, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	format := DetectFormat(raw)
+	parsedSchedule, err := parse(raw, format)
+	if err != nil {
+		return nil, err
+	}
+
+	entry = &cacheEntry{Validators: validators, Format: format, schedule: parsedSchedule}
+	c.setEntry(year, entry)
+
+	err = c.persist(year, raw, entry)
+	if err != nil {
+		fmt.Println("error persisting schedule: " + err.Error())
+	}
+
+	return parsedSchedule, nil
+}
+
+// getEntry returns the cached entry of the year, loading it from disk the first time
+func (c *CachedScheduleService) getEntry(year int) *cacheEntry {
+	c.mu.Lock()
+	entry, found := c.entries[year]
+	c.mu.Unlock()
+	if found {
+		return entry
+	}
+
+	entry, err := c.load(year)
+	if err != nil {
+		// a missing or corrupted cache just means the schedule has to be downloaded again
+		return nil
+	}
+	c.setEntry(year, entry)
+	return entry
+}
+
+func (c *CachedScheduleService) setEntry(year int, entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[int]*cacheEntry)
+	}
+	c.entries[year] = entry
+}
+
+func (c *CachedScheduleService) schedulePath(year int, format Format) string {
+	return filepath.Join(c.dir, fileName(year, format))
+}
+
+func (c *CachedScheduleService) metaPath(year int) string {
+	return filepath.Join(c.dir, strconv.Itoa(year)+".json")
+}
+
+// load reads the raw schedule and the validators of the year from the cache dir
+func (c *CachedScheduleService) load(year int) (*cacheEntry, error) {
+	if c.dir == "" {
+		return nil, errors.New("no cache dir")
+	}
+
+	meta, err := ioutil.ReadFile(c.metaPath(year))
+	if err != nil {
+		return nil, err
+	}
+	var entry cacheEntry
+	err = json.Unmarshal(meta, &entry)
+	if err != nil {
+		return nil, err
+	}
+
+	// the entries persisted before the frab JSON was supported have no format
+	if entry.Format == "" {
+		entry.Format = FormatXML
+	}
+	raw, err := ioutil.ReadFile(c.schedulePath(year, entry.Format))
+	if err != nil {
+		return nil, err
+	}
+
+	entry.schedule, err = parse(raw, entry.Format)
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// persist writes the raw schedule and the validators of the year in the cache dir
+func (c *CachedScheduleService) persist(year int, raw []byte, entry *cacheEntry) error {
+	if c.dir == "" {
+		return nil
+	}
+
+	err := os.MkdirAll(c.dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	meta, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	// the validators are written last, so they never refer to a partial schedule
+	err = writeFileAtomic(c.schedulePath(year, entry.Format), raw)
+	if err != nil {
+		return err
+	}
+	err = writeFileAtomic(c.metaPath(year), meta)
+	if err != nil {
+		return err
+	}
+
+	// a schedule of the other format is stale, and would be read first by a DirSource
+	other := FormatJSON
+	if entry.Format == FormatJSON {
+		other = FormatXML
+	}
+	err = os.Remove(c.schedulePath(year, other))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// parse parses the raw schedule in the format
+func parse(raw []byte, format Format) (*Schedule, error) {
+	if format == FormatJSON {
+		return ParseJSON(bytes.NewReader(raw))
+	}
+	return Parse(bytes.NewReader(raw))
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	err := ioutil.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
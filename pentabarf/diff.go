@@ -0,0 +1,121 @@
+package pentabarf
+
+import (
+	"sort"
+	"strings"
+)
+
+// Changes contains the differences between two versions of a Schedule
+type Changes struct {
+	Added           []*Event
+	Removed         []*Event
+	Cancelled       []*Event
+	Moved           []*EventChange
+	Retitled        []*EventChange
+	SpeakersChanged []*EventChange
+}
+
+// EventChange contains the old and the new version of a changed Event
+type EventChange struct {
+	Old *Event
+	New *Event
+}
+
+// IsEmpty returns true if the two versions of the Schedule have no differences
+func (c *Changes) IsEmpty() bool {
+	return len(c.Added) == 0 &&
+		len(c.Removed) == 0 &&
+		len(c.Cancelled) == 0 &&
+		len(c.Moved) == 0 &&
+		len(c.Retitled) == 0 &&
+		len(c.SpeakersChanged) == 0
+}
+
+// IsCancelled returns true if the event was marked as cancelled in its title
+func (e *Event) IsCancelled() bool {
+	title := strings.ToUpper(strings.TrimSpace(e.Title))
+	return strings.HasPrefix(title, "CANCELLED") || strings.HasPrefix(title, "CANCELED")
+}
+
+// Diff returns the changes of the events between the old and the new Schedule.
+// The events are matched by ID, and every list is ordered by ID.
+func Diff(oldSchedule, newSchedule *Schedule) *Changes {
+	changes := &Changes{
+		Added:           make([]*Event, 0),
+		Removed:         make([]*Event, 0),
+		Cancelled:       make([]*Event, 0),
+		Moved:           make([]*EventChange, 0),
+		Retitled:        make([]*EventChange, 0),
+		SpeakersChanged: make([]*EventChange, 0),
+	}
+
+	oldEvents := eventsByID(oldSchedule)
+	newEvents := eventsByID(newSchedule)
+
+	for _, id := range sortedIDs(newEvents) {
+		n := newEvents[id]
+		o, found := oldEvents[id]
+		if !found {
+			changes.Added = append(changes.Added, n)
+			continue
+		}
+
+		change := &EventChange{Old: o, New: n}
+		if n.IsCancelled() && !o.IsCancelled() {
+			changes.Cancelled = append(changes.Cancelled, n)
+		} else if o.Title != n.Title {
+			changes.Retitled = append(changes.Retitled, change)
+		}
+		if o.Room != n.Room || !o.Start.Equal(n.Start) || o.Duration != n.Duration {
+			changes.Moved = append(changes.Moved, change)
+		}
+		if !samePersons(o.Persons, n.Persons) {
+			changes.SpeakersChanged = append(changes.SpeakersChanged, change)
+		}
+	}
+
+	for _, id := range sortedIDs(oldEvents) {
+		if _, found := newEvents[id]; !found {
+			changes.Removed = append(changes.Removed, oldEvents[id])
+		}
+	}
+
+	return changes
+}
+
+func eventsByID(s *Schedule) map[int]*Event {
+	events := make(map[int]*Event)
+	if s == nil {
+		return events
+	}
+	for _, e := range s.GetAllEvents() {
+		events[e.ID] = e
+	}
+	return events
+}
+
+func sortedIDs(events map[int]*Event) []int {
+	ids := make([]int, 0, len(events))
+	for id := range events {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func samePersons(oldPersons, newPersons []*Person) bool {
+	if len(oldPersons) != len(newPersons) {
+		return false
+	}
+	ids := make(map[int]string)
+	for _, p := range oldPersons {
+		ids[p.ID] = p.Name
+	}
+	for _, p := range newPersons {
+		name, found := ids[p.ID]
+		if !found || name != p.Name {
+			return false
+		}
+	}
+	return true
+}
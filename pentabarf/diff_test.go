@@ -0,0 +1,90 @@
+package pentabarf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSchedule(events ...*Event) *Schedule {
+	return &Schedule{Days: []*Day{{Index: 1, Rooms: []*Room{{Name: "Room 1", Events: events}}}}}
+}
+
+func TestDiff(t *testing.T) {
+	start := time.Date(2018, time.February, 3, 10, 0, 0, 0, time.UTC)
+	mario := &Person{ID: 1, Name: "Mario Rossi"}
+	paolo := &Person{ID: 2, Name: "Paolo Bianchi"}
+
+	unchanged := &Event{ID: 1, Title: "Unchanged", Room: "Room 1", Start: start, Persons: []*Person{mario}}
+	removed := &Event{ID: 2, Title: "Removed", Room: "Room 1", Start: start}
+	added := &Event{ID: 3, Title: "Added", Room: "Room 1", Start: start}
+
+	oldCancelled := &Event{ID: 4, Title: "Talk", Room: "Room 1", Start: start}
+	newCancelled := &Event{ID: 4, Title: "CANCELLED: Talk", Room: "Room 1", Start: start}
+
+	oldMoved := &Event{ID: 5, Title: "Moved", Room: "Room 1", Start: start}
+	newMoved := &Event{ID: 5, Title: "Moved", Room: "Room 2", Start: start.Add(time.Hour)}
+
+	oldRetitled := &Event{ID: 6, Title: "Old title", Room: "Room 1", Start: start}
+	newRetitled := &Event{ID: 6, Title: "New title", Room: "Room 1", Start: start}
+
+	oldSpeakers := &Event{ID: 7, Title: "Speakers", Room: "Room 1", Start: start, Persons: []*Person{mario}}
+	newSpeakers := &Event{ID: 7, Title: "Speakers", Room: "Room 1", Start: start, Persons: []*Person{paolo}}
+
+	tt := []struct {
+		name       string
+		old        *Schedule
+		new        *Schedule
+		expChanges *Changes
+	}{
+		{
+			name: "no changes",
+			old:  newTestSchedule(unchanged),
+			new:  newTestSchedule(unchanged),
+			expChanges: &Changes{
+				Added:           []*Event{},
+				Removed:         []*Event{},
+				Cancelled:       []*Event{},
+				Moved:           []*EventChange{},
+				Retitled:        []*EventChange{},
+				SpeakersChanged: []*EventChange{},
+			},
+		},
+		{
+			name: "all changes",
+			old:  newTestSchedule(unchanged, removed, oldCancelled, oldMoved, oldRetitled, oldSpeakers),
+			new:  newTestSchedule(newSpeakers, newRetitled, newMoved, newCancelled, added, unchanged),
+			expChanges: &Changes{
+				Added:           []*Event{added},
+				Removed:         []*Event{removed},
+				Cancelled:       []*Event{newCancelled},
+				Moved:           []*EventChange{{Old: oldMoved, New: newMoved}},
+				Retitled:        []*EventChange{{Old: oldRetitled, New: newRetitled}},
+				SpeakersChanged: []*EventChange{{Old: oldSpeakers, New: newSpeakers}},
+			},
+		},
+		{
+			name: "from empty schedule",
+			old:  nil,
+			new:  newTestSchedule(added),
+			expChanges: &Changes{
+				Added:           []*Event{added},
+				Removed:         []*Event{},
+				Cancelled:       []*Event{},
+				Moved:           []*EventChange{},
+				Retitled:        []*EventChange{},
+				SpeakersChanged: []*EventChange{},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			changes := Diff(tc.old, tc.new)
+
+			assert.Equal(t, tc.expChanges, changes)
+			assert.Equal(t, tc.name == "no changes", changes.IsEmpty())
+		})
+	}
+}
@@ -2,60 +2,16 @@ package pentabarf
 
 import (
 	"encoding/xml"
-	"errors"
 	"io"
-	"net/http"
 	"strconv"
 	"strings"
 	"time"
 )
 
 const (
-	baseURL        = "https://fosdem.org"
 	yyyyMMddFormat = "2006-01-02"
 )
 
-type CachedScheduleService struct {
-	lastModified   string
-	cachedSchedule *Schedule
-}
-
-func (c *CachedScheduleService) GetSchedule(year int) (*Schedule, error) {
-	url := baseURL + "/" + strconv.Itoa(year) + "/schedule/xml"
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	if c.lastModified != "" && c.cachedSchedule != nil {
-		req.Header.Set("If-Modified-Since", c.lastModified)
-	}
-
-	scheduleResp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer scheduleResp.Body.Close()
-
-	if scheduleResp.StatusCode == http.StatusNotModified {
-		return c.cachedSchedule, nil
-	}
-
-	if scheduleResp.StatusCode == http.StatusOK {
-		parsedSchedule, err := Parse(scheduleResp.Body)
-		if err != nil {
-			return nil, err
-		}
-
-		c.lastModified = scheduleResp.Header.Get("Last-Modified")
-		c.cachedSchedule = parsedSchedule
-
-		return c.cachedSchedule, nil
-	}
-
-	return nil, errors.New("error from Fosdem server: " + strconv.Itoa(scheduleResp.StatusCode))
-}
-
 // Schedule contains the info about the Conference and the schedule of the days
 type Schedule struct {
 	Conference *Conference `xml:"conference"`
@@ -117,6 +73,7 @@ type Conference struct {
 	Title               string `xml:"title"`
 	Subtitle            string `xml:"subtitle"`
 	Venue               string `xml:"venue"`
+	City                string `xml:"city"`
 	StartDate           time.Time
 	StartDateStr        string `xml:"start"`
 	EndDate             time.Time
@@ -215,6 +172,8 @@ type Event struct {
 	Description string    `xml:"description"`
 	Persons     []*Person `xml:"persons>person"`
 	Links       []*Link   `xml:"links>link"`
+	// Code is the code of the submission in pretalx, only in the frab JSON
+	Code string `xml:"-"`
 }
 
 func (e *Event) String() string {
@@ -276,6 +235,9 @@ func ParseInLocation(xmlReader io.Reader, location *time.Location) (*Schedule, e
 		schedule.Days[dIndex] = d
 	}
 
+	// index the persons now, so the Schedule can be read concurrently
+	schedule.GetAllPersons()
+
 	return &schedule, nil
 }
 
@@ -1,17 +1,211 @@
 package pentabarf
 
 import (
-	"fmt"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/enrichman/api-fosdem/retry"
 	"github.com/stretchr/testify/assert"
 )
 
+// newTestServer serves the test schedule for 2017 and 2018, honouring the If-Modified-Since header
+func newTestServer(hits *int32) *httptest.Server {
+	lastModified := map[string]string{
+		"/2017/schedule/xml": "Mon, 30 Jan 2017 10:00:00 GMT",
+		"/2018/schedule/xml": "Tue, 30 Jan 2018 10:00:00 GMT",
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		lm, found := lastModified[r.URL.Path]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("If-Modified-Since") == lm {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Last-Modified", lm)
+		http.ServeFile(w, r, "pentabarf_test.xml")
+	}))
+}
+
 func TestCached(t *testing.T) {
-	srv := &CachedScheduleService{}
-	fmt.Println(srv.GetSchedule(2018))
-	fmt.Println(srv.GetSchedule(2018))
+	var hits int32
+	srv := newTestServer(&hits)
+	defer srv.Close()
+
+	cache := NewCachedScheduleService(NewHTTPSource(srv.URL, srv.Client(), nil), "")
+
+	s2018, err := cache.GetSchedule(context.Background(), 2018)
+	assert.Nil(t, err)
+	assert.Equal(t, "Conf TItle", s2018.Conference.Title)
+
+	// the validators of the 2018 must not be used for the 2017
+	s2017, err := cache.GetSchedule(context.Background(), 2017)
+	assert.Nil(t, err)
+	assert.False(t, s2017 == s2018)
+
+	cached, err := cache.GetSchedule(context.Background(), 2018)
+	assert.Nil(t, err)
+	assert.True(t, cached == s2018)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&hits))
+
+	_, err = cache.GetSchedule(context.Background(), 2010)
+	assert.Equal(t, ErrScheduleNotFound, err)
+}
+
+func TestHTTPSourceRetry(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		http.ServeFile(w, r, "pentabarf_test.xml")
+	}))
+	defer srv.Close()
+
+	retrier := retry.New(retry.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond}, nil)
+	raw, _, err := NewHTTPSource(srv.URL, srv.Client(), retrier).Fetch(context.Background(), 2018, Validators{})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, raw)
+	assert.Equal(t, int32(3), hits)
+
+	// without a retrier the first error is returned
+	atomic.StoreInt32(&hits, 0)
+	_, _, err = NewHTTPSource(srv.URL, srv.Client(), nil).Fetch(context.Background(), 2018, Validators{})
+	assert.NotNil(t, err)
+}
+
+func TestHTTPSourceCancelled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	// the backoff of the retries is interrupted when the context is done
+	retrier := retry.New(retry.Policy{MaxAttempts: 5, BaseDelay: time.Minute}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := NewCachedScheduleService(NewHTTPSource(srv.URL, srv.Client(), retrier), "").GetSchedule(ctx, 2018)
+	assert.NotNil(t, err)
+	assert.True(t, time.Since(start) < 10*time.Second)
+}
+
+// blockingSource serves the test schedule, blocking every fetch until release is closed
+type blockingSource struct {
+	fetches int32
+	release chan struct{}
+}
+
+func (s *blockingSource) Fetch(ctx context.Context, year int, v Validators) ([]byte, Validators, error) {
+	atomic.AddInt32(&s.fetches, 1)
+	<-s.release
+	raw, err := ioutil.ReadFile("pentabarf_test.xml")
+	return raw, Validators{ETag: `"v1"`}, err
+}
+
+// waitingContext signals when Done is called, as the callers waiting for the fetch of another one do
+type waitingContext struct {
+	context.Context
+	waiting chan<- struct{}
+	once    sync.Once
+}
+
+func (c *waitingContext) Done() <-chan struct{} {
+	c.once.Do(func() { c.waiting <- struct{}{} })
+	return c.Context.Done()
+}
+
+func TestCachedConcurrent(t *testing.T) {
+	const callers = 20
+	source := &blockingSource{release: make(chan struct{})}
+	cache := NewCachedScheduleService(source, "")
+
+	waiting := make(chan struct{}, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := &waitingContext{Context: context.Background(), waiting: waiting}
+			s, err := cache.GetSchedule(ctx, 2018)
+			assert.Nil(t, err)
+			assert.NotNil(t, s)
+		}()
+	}
+
+	// the fetch ends only when all the other callers are waiting for it
+	timeout := time.After(time.Second)
+	for i := 0; i < callers-1; i++ {
+		select {
+		case <-waiting:
+		case <-timeout:
+			close(source.release)
+			t.Fatal("the callers did not wait for the fetch in flight")
+		}
+	}
+	close(source.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&source.fetches))
+}
+
+func TestCachedDisk(t *testing.T) {
+	var hits int32
+	srv := newTestServer(&hits)
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "pentabarf")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = NewCachedScheduleService(NewHTTPSource(srv.URL, srv.Client(), nil), dir).GetSchedule(context.Background(), 2018)
+	assert.Nil(t, err)
+
+	// a new service (i.e. after a restart) reuses the schedule on disk
+	restarted := NewCachedScheduleService(NewHTTPSource(srv.URL, srv.Client(), nil), dir)
+	s, err := restarted.GetSchedule(context.Background(), 2018)
+	assert.Nil(t, err)
+	assert.Equal(t, "Conf TItle", s.Conference.Title)
+
+	// the cache dir can also be used as an archive
+	s, err = NewCachedScheduleService(NewDirSource(dir), "").GetSchedule(context.Background(), 2018)
+	assert.Nil(t, err)
+	assert.Equal(t, "Conf TItle", s.Conference.Title)
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+}
+
+func TestFileSource(t *testing.T) {
+	source := NewFileSource("pentabarf_test.xml")
+
+	raw, v, err := source.Fetch(context.Background(), 2018, Validators{})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, raw)
+	assert.NotEmpty(t, v.LastModified)
+
+	_, _, err = source.Fetch(context.Background(), 2018, v)
+	assert.Equal(t, ErrNotModified, err)
+
+	// the schedule is not returned for the other years
+	_, _, err = source.Fetch(context.Background(), 2017, Validators{})
+	assert.Equal(t, ErrScheduleNotFound, err)
+	_, _, err = source.Fetch(context.Background(), 2017, v)
+	assert.Equal(t, ErrScheduleNotFound, err)
+
+	_, _, err = NewFileSource("missing.xml").Fetch(context.Background(), 2018, Validators{})
+	assert.Equal(t, ErrScheduleNotFound, err)
 }
 
 func Test_parseConference(t *testing.T) {
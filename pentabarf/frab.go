@@ -0,0 +1,255 @@
+package pentabarf
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"time"
+)
+
+// the fields of the frab JSON mapped in the Schedule
+type frabSchedule struct {
+	Schedule struct {
+		Conference *frabConference `json:"conference"`
+	} `json:"schedule"`
+}
+
+type frabConference struct {
+	Title            string     `json:"title"`
+	Subtitle         string     `json:"subtitle"`
+	Venue            string     `json:"venue"`
+	City             string     `json:"city"`
+	Start            string     `json:"start"`
+	End              string     `json:"end"`
+	DaysCount        int        `json:"daysCount"`
+	TimeslotDuration string     `json:"timeslot_duration"`
+	Rooms            []frabRoom `json:"rooms"`
+	Days             []frabDay  `json:"days"`
+}
+
+type frabRoom struct {
+	Name string `json:"name"`
+}
+
+type frabDay struct {
+	Index    int                    `json:"index"`
+	Date     string                 `json:"date"`
+	DayStart string                 `json:"day_start"`
+	Rooms    map[string][]frabEvent `json:"rooms"`
+}
+
+type frabEvent struct {
+	ID          int          `json:"id"`
+	Code        string       `json:"code"`
+	Date        string       `json:"date"`
+	Start       string       `json:"start"`
+	Duration    string       `json:"duration"`
+	Room        string       `json:"room"`
+	Slug        string       `json:"slug"`
+	Title       string       `json:"title"`
+	Subtitle    string       `json:"subtitle"`
+	Track       string       `json:"track"`
+	Type        string       `json:"type"`
+	Language    string       `json:"language"`
+	Abstract    string       `json:"abstract"`
+	Description string       `json:"description"`
+	Persons     []frabPerson `json:"persons"`
+	Links       []frabLink   `json:"links"`
+	Attachments []frabLink   `json:"attachments"`
+}
+
+type frabPerson struct {
+	ID         int    `json:"id"`
+	PublicName string `json:"public_name"`
+	Name       string `json:"name"`
+}
+
+type frabLink struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+// ParseJSON will parse the frab JSON returning the correspoding Schedule
+// using the Europe/Brussels location (FOSDEM)
+func ParseJSON(jsonReader io.Reader) (*Schedule, error) {
+	location, err := time.LoadLocation("Europe/Brussels")
+	if err != nil {
+		return nil, err
+	}
+	return ParseJSONInLocation(jsonReader, location)
+}
+
+// ParseJSONInLocation will parse the frab JSON returning the correspoding Schedule.
+// The rooms of every day are sorted as listed by the conference, and the attachments
+// of the events are added to their links.
+func ParseJSONInLocation(jsonReader io.Reader, location *time.Location) (*Schedule, error) {
+	var doc frabSchedule
+	err := json.NewDecoder(jsonReader).Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+	c := doc.Schedule.Conference
+	if c == nil {
+		return nil, errors.New("missing conference")
+	}
+
+	var schedule Schedule
+	schedule.Conference, err = parseFrabConference(c, location)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fd := range c.Days {
+		d, err := parseDay(&Day{Index: fd.Index, DateStr: fd.Date}, location)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range frabRoomNames(c.Rooms, fd.Rooms) {
+			r := &Room{Name: name}
+			for _, fe := range fd.Rooms[name] {
+				e, err := parseFrabEvent(fe, d.Date, location)
+				if err != nil {
+					return nil, err
+				}
+				r.Events = append(r.Events, e)
+			}
+			d.Rooms = append(d.Rooms, r)
+		}
+		schedule.Days = append(schedule.Days, d)
+	}
+
+	// the day change is not in the frab JSON, but every day starts at it
+	if len(c.Days) > 0 && c.Days[0].DayStart != "" {
+		dayStart, err := time.Parse(time.RFC3339, c.Days[0].DayStart)
+		if err != nil {
+			return nil, err
+		}
+		schedule.Conference.DayChange = dayStart.In(location).Sub(schedule.Days[0].Date)
+		schedule.Conference.DayChangeStr = formatDuration(schedule.Conference.DayChange, true)
+	}
+
+	// index the persons now, so the Schedule can be read concurrently
+	schedule.GetAllPersons()
+
+	return &schedule, nil
+}
+
+func parseFrabConference(fc *frabConference, location *time.Location) (*Conference, error) {
+	c := &Conference{
+		Title:               fc.Title,
+		Subtitle:            fc.Subtitle,
+		Venue:               fc.Venue,
+		City:                fc.City,
+		StartDateStr:        isoDate(fc.Start),
+		EndDateStr:          isoDate(fc.End),
+		Days:                fc.DaysCount,
+		TimeslotDurationStr: fc.TimeslotDuration,
+	}
+	if c.Days == 0 {
+		c.Days = len(fc.Days)
+	}
+
+	var err error
+	c.StartDate, err = time.ParseInLocation(yyyyMMddFormat, c.StartDateStr, location)
+	if err != nil {
+		return nil, err
+	}
+
+	c.EndDate, err = time.ParseInLocation(yyyyMMddFormat, c.EndDateStr, location)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.TimeslotDurationStr != "" {
+		c.TimeslotDuration, err = getDurationByString(c.TimeslotDurationStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func parseFrabEvent(fe frabEvent, day time.Time, location *time.Location) (*Event, error) {
+	e := &Event{
+		ID:          fe.ID,
+		Code:        fe.Code,
+		StartStr:    fe.Start,
+		DurationStr: fe.Duration,
+		Room:        fe.Room,
+		Slug:        fe.Slug,
+		Title:       fe.Title,
+		Subtitle:    fe.Subtitle,
+		Track:       fe.Track,
+		Type:        fe.Type,
+		Language:    fe.Language,
+		Abstract:    fe.Abstract,
+		Description: fe.Description,
+	}
+
+	var err error
+	if fe.Date != "" {
+		// the ISO-8601 date is exact also for the events after midnight
+		e.Start, err = time.Parse(time.RFC3339, fe.Date)
+		if err != nil {
+			return nil, err
+		}
+		e.Start = e.Start.In(location)
+	} else {
+		hhMM, err := getDurationByString(fe.Start)
+		if err != nil {
+			return nil, err
+		}
+		e.Start = day.Add(hhMM)
+	}
+
+	e.Duration, err = getDurationByString(fe.Duration)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range fe.Persons {
+		name := p.PublicName
+		if name == "" {
+			name = p.Name
+		}
+		e.Persons = append(e.Persons, &Person{ID: p.ID, Name: name})
+	}
+	for _, l := range append(fe.Links, fe.Attachments...) {
+		e.Links = append(e.Links, &Link{URL: l.URL, Text: l.Title})
+	}
+
+	return e, nil
+}
+
+// frabRoomNames returns the names of the rooms of the day, in the order of the conference
+// and then by name for the ones not listed
+func frabRoomNames(rooms []frabRoom, dayRooms map[string][]frabEvent) []string {
+	names := make([]string, 0, len(dayRooms))
+	listed := make(map[string]bool)
+	for _, r := range rooms {
+		if _, found := dayRooms[r.Name]; found && !listed[r.Name] {
+			names = append(names, r.Name)
+			listed[r.Name] = true
+		}
+	}
+
+	others := make([]string, 0)
+	for name := range dayRooms {
+		if !listed[name] {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	return append(names, others...)
+}
+
+// isoDate returns the date of an ISO-8601 date or date-time
+func isoDate(str string) string {
+	if len(str) > len(yyyyMMddFormat) {
+		return str[:len(yyyyMMddFormat)]
+	}
+	return str
+}
@@ -0,0 +1,145 @@
+package pentabarf
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseJSON(t *testing.T) {
+	location, _ := time.LoadLocation("Europe/Brussels")
+
+	f, err := os.Open("pentabarf_test.xml")
+	assert.Nil(t, err)
+	defer f.Close()
+	expected, err := ParseInLocation(f, location)
+	assert.Nil(t, err)
+
+	// the frab JSON of the test schedule has the feedback links as attachments
+	j, err := os.Open("frab_test.json")
+	assert.Nil(t, err)
+	defer j.Close()
+	schedule, err := ParseJSONInLocation(j, location)
+	assert.Nil(t, err)
+
+	assert.Equal(t, expected.Conference, schedule.Conference)
+	assert.Equal(t, len(expected.Days), len(schedule.Days))
+	for i, d := range schedule.Days {
+		assert.Equal(t, expected.Days[i].Index, d.Index)
+		assert.Equal(t, expected.Days[i].Date, d.Date)
+		assert.Equal(t, expected.Days[i].Rooms, d.Rooms)
+	}
+	assert.Equal(t, expected.GetAllEvents(), schedule.GetAllEvents())
+	assert.Equal(t, len(expected.GetAllPersons()), len(schedule.GetAllPersons()))
+
+	_, err = ParseJSON(strings.NewReader(`{"schedule": {}}`))
+	assert.NotNil(t, err)
+	_, err = ParseJSON(strings.NewReader(`<schedule></schedule>`))
+	assert.NotNil(t, err)
+}
+
+func Test_parseFrabEvent(t *testing.T) {
+	location, _ := time.LoadLocation("Europe/Brussels")
+	day := time.Date(2018, 2, 3, 0, 0, 0, 0, location)
+
+	tt := []struct {
+		name          string
+		event         frabEvent
+		expectedStart time.Time
+		expectedErr   bool
+	}{
+		{
+			name:          "ISO-8601 date in another zone",
+			event:         frabEvent{Code: "ABC12", Date: "2018-02-03T09:00:00Z", Start: "10:00", Duration: "00:50"},
+			expectedStart: time.Date(2018, 2, 3, 10, 0, 0, 0, location),
+		},
+		{
+			name:          "after midnight",
+			event:         frabEvent{Date: "2018-02-04T00:30:00+01:00", Start: "00:30", Duration: "01:00"},
+			expectedStart: time.Date(2018, 2, 4, 0, 30, 0, 0, location),
+		},
+		{
+			name:          "no date",
+			event:         frabEvent{Start: "10:00", Duration: "00:50"},
+			expectedStart: time.Date(2018, 2, 3, 10, 0, 0, 0, location),
+		},
+		{
+			name:        "wrong date",
+			event:       frabEvent{Date: "2018-02-03 10:00", Start: "10:00", Duration: "00:50"},
+			expectedErr: true,
+		},
+		{
+			name:        "wrong duration",
+			event:       frabEvent{Date: "2018-02-03T10:00:00+01:00", Start: "10:00", Duration: "fifty"},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e, err := parseFrabEvent(tc.event, day, location)
+			if tc.expectedErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.True(t, tc.expectedStart.Equal(e.Start), e.Start.String())
+			assert.Equal(t, location, e.Start.Location())
+			assert.Equal(t, tc.event.Code, e.Code)
+		})
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	assert.Equal(t, FormatJSON, DetectFormat([]byte("\n  {\"schedule\": {}}")))
+	assert.Equal(t, FormatXML, DetectFormat([]byte(`<?xml version="1.0"?><schedule/>`)))
+	assert.Equal(t, FormatXML, DetectFormat(nil))
+}
+
+func TestCachedJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/2017/schedule/xml":
+			http.ServeFile(w, r, "pentabarf_test.xml")
+		case "/2018/schedule/json":
+			http.ServeFile(w, r, "frab_test.json")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "pentabarf")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// the format is chosen for every edition
+	source := NewHTTPSource(srv.URL, srv.Client(), nil)
+	source.Formats = map[int]Format{2018: FormatJSON}
+	cache := NewCachedScheduleService(source, dir)
+
+	for _, year := range []int{2017, 2018} {
+		s, err := cache.GetSchedule(context.Background(), year)
+		assert.Nil(t, err)
+		assert.Equal(t, "Conf TItle", s.Conference.Title)
+		assert.Len(t, s.GetAllEvents(), 6)
+	}
+	_, err = os.Stat(filepath.Join(dir, "2018.schedule.json"))
+	assert.Nil(t, err)
+
+	// the cache dir, with schedules of both the formats, can be used as an archive
+	archive := NewCachedScheduleService(NewDirSource(dir), "")
+	for _, year := range []int{2017, 2018} {
+		s, err := archive.GetSchedule(context.Background(), year)
+		assert.Nil(t, err)
+		assert.Len(t, s.GetAllEvents(), 6)
+	}
+}
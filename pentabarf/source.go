@@ -0,0 +1,268 @@
+package pentabarf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/enrichman/api-fosdem/retry"
+)
+
+const defaultBaseURL = "https://fosdem.org"
+
+var (
+	// ErrNotModified is returned by a ScheduleSource when the schedule did not change since the passed Validators
+	ErrNotModified = errors.New("schedule not modified")
+	// ErrScheduleNotFound is returned by a ScheduleSource when the schedule of the year does not exist
+	ErrScheduleNotFound = errors.New("schedule not found")
+)
+
+// Format is the format a schedule is published in
+type Format string
+
+const (
+	// FormatXML is the Pentabarf XML
+	FormatXML Format = "xml"
+	// FormatJSON is the frab JSON, also exported by pretalx as schedule.json
+	FormatJSON Format = "json"
+)
+
+// DetectFormat returns the format of the raw schedule, looking at its first character
+func DetectFormat(raw []byte) Format {
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '{' {
+		return FormatJSON
+	}
+	return FormatXML
+}
+
+// fileName returns the name of the file of the schedule of the year, used by the DirSource and the cache.
+// The frab JSON is not named <year>.json, that is the name of the validators in the cache.
+func fileName(year int, format Format) string {
+	if format == FormatJSON {
+		return strconv.Itoa(year) + ".schedule.json"
+	}
+	return strconv.Itoa(year) + ".xml"
+}
+
+// Validators identify a version of a schedule, and are used to avoid fetching it again if not changed
+type Validators struct {
+	LastModified string `json:"last_modified,omitempty"`
+	ETag         string `json:"etag,omitempty"`
+}
+
+// ScheduleSource provides the raw schedules, as Pentabarf XML or frab JSON
+type ScheduleSource interface {
+	// Fetch returns the schedule of the year with its Validators,
+	// or ErrNotModified if it did not change since the passed ones.
+	// The fetch stops when the context is done.
+	Fetch(ctx context.Context, year int, v Validators) ([]byte, Validators, error)
+}
+
+// HTTPSource fetches the schedules from a FOSDEM website, or a mirror of it
+type HTTPSource struct {
+	// Formats are the formats fetched for the years, the Pentabarf XML if missing
+	Formats map[int]Format
+
+	baseURL string
+	client  *http.Client
+	retrier *retry.Retrier
+}
+
+// NewHTTPSource returns an HTTPSource fetching the schedules from baseURL/<year>/schedule/xml,
+// or baseURL/<year>/schedule/json for the years in the frab JSON format.
+// If empty, baseURL and client default to https://fosdem.org and the http.DefaultClient.
+// If the retrier is not nil the requests failed with a timeout, a refused or reset connection or a 5xx are retried.
+func NewHTTPSource(baseURL string, client *http.Client, retrier *retry.Retrier) *HTTPSource {
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPSource{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  client,
+		retrier: retrier,
+	}
+}
+
+// Fetch fetches the schedule of the year with a conditional request.
+// The request and its retries are cancelled when the context is done.
+func (s *HTTPSource) Fetch(ctx context.Context, year int, v Validators) ([]byte, Validators, error) {
+	format := s.Formats[year]
+	if format == "" {
+		format = FormatXML
+	}
+	url := s.baseURL + "/" + strconv.Itoa(year) + "/schedule/" + string(format)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, v, err
+	}
+	req = req.WithContext(ctx)
+
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+
+	scheduleResp, err := s.do(req)
+	if err != nil {
+		return nil, v, err
+	}
+	defer scheduleResp.Body.Close()
+
+	switch scheduleResp.StatusCode {
+	case http.StatusOK:
+		raw, err := ioutil.ReadAll(scheduleResp.Body)
+		if err != nil {
+			return nil, v, err
+		}
+		return raw, Validators{
+			LastModified: scheduleResp.Header.Get("Last-Modified"),
+			ETag:         scheduleResp.Header.Get("ETag"),
+		}, nil
+	case http.StatusNotModified:
+		return nil, v, ErrNotModified
+	case http.StatusNotFound:
+		return nil, v, ErrScheduleNotFound
+	}
+
+	return nil, v, errors.New("error from Fosdem server: " + strconv.Itoa(scheduleResp.StatusCode))
+}
+
+// do sends the request, retrying it with the retrier if set
+func (s *HTTPSource) do(req *http.Request) (*http.Response, error) {
+	if s.retrier == nil {
+		return s.client.Do(req)
+	}
+
+	var resp *http.Response
+	err := s.retrier.Do(req.Context(), req.URL.String(), func() error {
+		var err error
+		resp, err = s.client.Do(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+			resp.Body.Close()
+			return &retry.StatusError{StatusCode: resp.StatusCode}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// FileSource reads the schedule from a local file, for the year of its conference
+type FileSource struct {
+	path string
+}
+
+// NewFileSource returns a FileSource reading the schedule from the path
+func NewFileSource(path string) *FileSource {
+	return &FileSource{path}
+}
+
+// Fetch reads the file, if modified since the passed Validators.
+// The other years are not found, so they are not indexed with the same schedule.
+func (s *FileSource) Fetch(ctx context.Context, year int, v Validators) ([]byte, Validators, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, v, err
+	}
+
+	// the file is always read, to check its year also when not modified
+	raw, newValidators, err := readFile(s.path, Validators{})
+	if err != nil {
+		return nil, v, err
+	}
+	fileYear, err := conferenceYear(raw)
+	if err != nil {
+		return nil, v, err
+	}
+	if fileYear != year {
+		return nil, v, ErrScheduleNotFound
+	}
+	if v.LastModified == newValidators.LastModified {
+		return nil, v, ErrNotModified
+	}
+	return raw, newValidators, nil
+}
+
+// conferenceYear returns the year of the start of the conference of the raw schedule
+func conferenceYear(raw []byte) (int, error) {
+	if DetectFormat(raw) == FormatJSON {
+		schedule, err := ParseJSON(bytes.NewReader(raw))
+		if err != nil {
+			return 0, err
+		}
+		return schedule.Conference.StartDate.Year(), nil
+	}
+
+	// the conference comes before the events, so the rest of the XML is not parsed
+	conference, err := Stream(bytes.NewReader(raw), time.UTC, func(day *Day, room *Room, ev *Event) error {
+		return ErrStopStream
+	})
+	if err != nil {
+		return 0, err
+	}
+	if conference == nil {
+		return 0, errors.New("missing conference")
+	}
+	return conference.StartDate.Year(), nil
+}
+
+// DirSource reads the schedules from a directory containing a <year>.xml file for every year,
+// or a <year>.schedule.json file in the frab JSON format
+type DirSource struct {
+	dir string
+}
+
+// NewDirSource returns a DirSource reading the schedules from the dir
+func NewDirSource(dir string) *DirSource {
+	return &DirSource{dir}
+}
+
+// Fetch reads the <year>.xml file, or the <year>.schedule.json one, if modified since the passed Validators
+func (s *DirSource) Fetch(ctx context.Context, year int, v Validators) ([]byte, Validators, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, v, err
+	}
+	raw, v, err := readFile(filepath.Join(s.dir, fileName(year, FormatXML)), v)
+	if err == ErrScheduleNotFound {
+		return readFile(filepath.Join(s.dir, fileName(year, FormatJSON)), v)
+	}
+	return raw, v, err
+}
+
+// readFile reads the file using its modification time as validator
+func readFile(path string, v Validators) ([]byte, Validators, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, v, ErrScheduleNotFound
+	}
+	if err != nil {
+		return nil, v, err
+	}
+
+	lastModified := info.ModTime().UTC().Format(http.TimeFormat)
+	if v.LastModified == lastModified {
+		return nil, v, ErrNotModified
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, v, err
+	}
+	return raw, Validators{LastModified: lastModified}, nil
+}
@@ -0,0 +1,103 @@
+package pentabarf
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ErrStopStream can be returned by the callback of Stream to stop the parsing without errors
+var ErrStopStream = errors.New("stop stream")
+
+// EventFunc is called by Stream with every event, and the day and the room of the event
+type EventFunc func(day *Day, room *Room, ev *Event) error
+
+// Stream parses the Pentabarf XML one event at a time, calling fn with every event fully parsed
+// as soon as it is read, so the memory used does not depend on the size of the schedule.
+// The days and the rooms passed to fn do not contain their rooms and events.
+// The parsing stops at the first error returned by fn, that is returned unless it is ErrStopStream.
+// The Conference is returned if it was read before the end of the parsing.
+func Stream(xmlReader io.Reader, location *time.Location, fn EventFunc) (*Conference, error) {
+	decoder := xml.NewDecoder(xmlReader)
+
+	var conference *Conference
+	var day *Day
+	var room *Room
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return conference, nil
+		}
+		if err != nil {
+			return conference, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "conference":
+				var c Conference
+				if err := decoder.DecodeElement(&c, &t); err != nil {
+					return nil, err
+				}
+				conference, err = parseConference(&c, location)
+				if err != nil {
+					return nil, err
+				}
+
+			case "day":
+				day = &Day{DateStr: attr(t, "date")}
+				if index := attr(t, "index"); index != "" {
+					if day.Index, err = strconv.Atoi(index); err != nil {
+						return conference, err
+					}
+				}
+				if day, err = parseDay(day, location); err != nil {
+					return conference, err
+				}
+
+			case "room":
+				room = &Room{Name: attr(t, "name")}
+
+			case "event":
+				if day == nil || room == nil {
+					return conference, errors.New("event outside of a room")
+				}
+				var e Event
+				if err := decoder.DecodeElement(&e, &t); err != nil {
+					return conference, err
+				}
+				ev, err := parseEvent(&e, day.Date, location)
+				if err != nil {
+					return conference, err
+				}
+				if err := fn(day, room, ev); err != nil {
+					if err == ErrStopStream {
+						return conference, nil
+					}
+					return conference, err
+				}
+			}
+
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "day":
+				day = nil
+			case "room":
+				room = nil
+			}
+		}
+	}
+}
+
+// attr returns the value of the attribute of the element, or an empty string
+func attr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
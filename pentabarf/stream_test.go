@@ -0,0 +1,65 @@
+package pentabarf
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStream(t *testing.T) {
+	location, _ := time.LoadLocation("Europe/Brussels")
+
+	// the events streamed are the same of the whole schedule, also for the real one of the 2018
+	for _, file := range []string{"pentabarf_test.xml", "../schedule.xml"} {
+		t.Run(file, func(t *testing.T) {
+			f, err := os.Open(file)
+			assert.Nil(t, err)
+			defer f.Close()
+			schedule, err := ParseInLocation(f, location)
+			assert.Nil(t, err)
+
+			f.Seek(0, 0)
+			events := make([]*Event, 0)
+			conference, err := Stream(f, location, func(day *Day, room *Room, ev *Event) error {
+				assert.True(t, ev.Start.After(day.Date))
+				assert.Nil(t, day.Rooms)
+				assert.Nil(t, room.Events)
+				events = append(events, ev)
+				return nil
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, schedule.Conference, conference)
+			assert.NotEmpty(t, events)
+			assert.Equal(t, schedule.GetAllEvents(), events)
+		})
+	}
+}
+
+func TestStreamStop(t *testing.T) {
+	location, _ := time.LoadLocation("Europe/Brussels")
+	f, err := os.Open("pentabarf_test.xml")
+	assert.Nil(t, err)
+	defer f.Close()
+
+	calls := 0
+	conference, err := Stream(f, location, func(day *Day, room *Room, ev *Event) error {
+		calls++
+		assert.Equal(t, 1, day.Index)
+		assert.Equal(t, "Room 1", room.Name)
+		assert.Equal(t, 123, ev.ID)
+		return ErrStopStream
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "Conf TItle", conference.Title)
+	assert.Equal(t, 1, calls)
+
+	f.Seek(0, 0)
+	callbackErr := errors.New("callback error")
+	_, err = Stream(f, location, func(day *Day, room *Room, ev *Event) error {
+		return callbackErr
+	})
+	assert.Equal(t, callbackErr, err)
+}
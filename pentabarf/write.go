@@ -0,0 +1,132 @@
+package pentabarf
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// the elements of the Pentabarf XML, in the order read by the apps
+type xmlSchedule struct {
+	XMLName    xml.Name      `xml:"schedule"`
+	Conference xmlConference `xml:"conference"`
+	Days       []xmlDay      `xml:"day"`
+}
+
+type xmlConference struct {
+	Title            string `xml:"title"`
+	Subtitle         string `xml:"subtitle"`
+	Venue            string `xml:"venue"`
+	City             string `xml:"city"`
+	Start            string `xml:"start"`
+	End              string `xml:"end"`
+	Days             int    `xml:"days"`
+	DayChange        string `xml:"day_change"`
+	TimeslotDuration string `xml:"timeslot_duration"`
+}
+
+type xmlDay struct {
+	Index int       `xml:"index,attr"`
+	Date  string    `xml:"date,attr"`
+	Rooms []xmlRoom `xml:"room"`
+}
+
+type xmlRoom struct {
+	Name   string     `xml:"name,attr"`
+	Events []xmlEvent `xml:"event"`
+}
+
+type xmlEvent struct {
+	ID          int       `xml:"id,attr"`
+	Start       string    `xml:"start"`
+	Duration    string    `xml:"duration"`
+	Room        string    `xml:"room"`
+	Slug        string    `xml:"slug"`
+	Title       string    `xml:"title"`
+	Subtitle    string    `xml:"subtitle"`
+	Track       string    `xml:"track"`
+	Type        string    `xml:"type"`
+	Language    string    `xml:"language"`
+	Abstract    string    `xml:"abstract"`
+	Description string    `xml:"description"`
+	Persons     []*Person `xml:"persons>person"`
+	Links       []*Link   `xml:"links>link"`
+}
+
+// Write writes the Schedule as Pentabarf XML. The dates and the times are rendered from the
+// parsed fields (i.e. Start and Duration), so the changes to the Schedule are written.
+func Write(w io.Writer, s *Schedule) error {
+	if s.Conference == nil {
+		return errors.New("missing conference")
+	}
+
+	c := s.Conference
+	schedule := xmlSchedule{
+		Conference: xmlConference{
+			Title:            c.Title,
+			Subtitle:         c.Subtitle,
+			Venue:            c.Venue,
+			City:             c.City,
+			Start:            c.StartDate.Format(yyyyMMddFormat),
+			End:              c.EndDate.Format(yyyyMMddFormat),
+			Days:             c.Days,
+			DayChange:        formatDuration(c.DayChange, true),
+			TimeslotDuration: formatDuration(c.TimeslotDuration, true),
+		},
+		Days: make([]xmlDay, 0, len(s.Days)),
+	}
+
+	for _, d := range s.Days {
+		day := xmlDay{Index: d.Index, Date: d.Date.Format(yyyyMMddFormat)}
+		for _, r := range d.Rooms {
+			room := xmlRoom{Name: r.Name}
+			for _, e := range r.Events {
+				room.Events = append(room.Events, writeEvent(e, d.Date))
+			}
+			day.Rooms = append(day.Rooms, room)
+		}
+		schedule.Days = append(schedule.Days, day)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(schedule); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func writeEvent(e *Event, day time.Time) xmlEvent {
+	return xmlEvent{
+		ID: e.ID,
+		// the start is the time from the beginning of the day, as when parsed
+		Start:       formatDuration(e.Start.Sub(day), false),
+		Duration:    formatDuration(e.Duration, false),
+		Room:        e.Room,
+		Slug:        e.Slug,
+		Title:       e.Title,
+		Subtitle:    e.Subtitle,
+		Track:       e.Track,
+		Type:        e.Type,
+		Language:    e.Language,
+		Abstract:    e.Abstract,
+		Description: e.Description,
+		Persons:     e.Persons,
+		Links:       e.Links,
+	}
+}
+
+// formatDuration formats the duration as HH:MM, adding the seconds if requested or not 0
+func formatDuration(d time.Duration, seconds bool) string {
+	hh, mm, ss := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	if seconds || ss != 0 {
+		return fmt.Sprintf("%02d:%02d:%02d", hh, mm, ss)
+	}
+	return fmt.Sprintf("%02d:%02d", hh, mm)
+}
@@ -0,0 +1,60 @@
+package pentabarf
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrite(t *testing.T) {
+	location, _ := time.LoadLocation("Europe/Brussels")
+
+	// the schedule parsed again is the same, also for the real one of the 2018
+	for _, file := range []string{"pentabarf_test.xml", "../schedule.xml"} {
+		t.Run(file, func(t *testing.T) {
+			f, err := os.Open(file)
+			assert.Nil(t, err)
+			defer f.Close()
+			schedule, err := ParseInLocation(f, location)
+			assert.Nil(t, err)
+
+			var buf bytes.Buffer
+			assert.Nil(t, Write(&buf, schedule))
+
+			written, err := ParseInLocation(&buf, location)
+			assert.Nil(t, err)
+			assert.Equal(t, schedule, written)
+		})
+	}
+}
+
+func TestWriteChanged(t *testing.T) {
+	location, _ := time.LoadLocation("Europe/Brussels")
+	f, err := os.Open("pentabarf_test.xml")
+	assert.Nil(t, err)
+	defer f.Close()
+	schedule, err := ParseInLocation(f, location)
+	assert.Nil(t, err)
+
+	// the times are written from the parsed fields
+	e := schedule.Days[0].Rooms[0].Events[0]
+	e.Start = e.Start.Add(90 * time.Minute)
+	e.Duration = 25 * time.Minute
+
+	var buf bytes.Buffer
+	assert.Nil(t, Write(&buf, schedule))
+	assert.Contains(t, buf.String(), "<start>11:30</start>\n        <duration>00:25</duration>")
+	assert.Contains(t, buf.String(), "<day_change>09:31:10</day_change>")
+
+	assert.NotNil(t, Write(&buf, &Schedule{}))
+}
+
+func Test_formatDuration(t *testing.T) {
+	assert.Equal(t, "09:05", formatDuration(9*time.Hour+5*time.Minute, false))
+	assert.Equal(t, "09:05:00", formatDuration(9*time.Hour+5*time.Minute, true))
+	assert.Equal(t, "00:00:30", formatDuration(30*time.Second, false))
+	assert.Equal(t, "25:00", formatDuration(25*time.Hour, false))
+}
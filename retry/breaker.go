@@ -0,0 +1,145 @@
+package retry
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// State is the state of a Breaker
+type State int
+
+// the states of a Breaker
+const (
+	// Closed lets all the requests through
+	Closed State = iota
+	// Open pauses the requests until the cooldown is over
+	Open
+	// HalfOpen lets a single request through, to check if the website recovered
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// halfOpenPoll is how often the requests waiting for the trial request of the half-open state check it
+const halfOpenPoll = 100 * time.Millisecond
+
+// Breaker is a circuit breaker that opens after some consecutive retryable errors,
+// pausing all the requests for a cooldown
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	trial    bool
+
+	now      func() time.Time
+	onChange func(from, to State)
+}
+
+// NewBreaker returns a Breaker that opens after threshold consecutive failures, for the cooldown
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// State returns the current state
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// wait blocks until a request can be sent, or the context is done
+func (b *Breaker) wait(ctx context.Context, sleep func(ctx context.Context, d time.Duration) error) error {
+	for {
+		d := b.allow()
+		if d == 0 {
+			return nil
+		}
+		if err := sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+// allow returns 0 if a request can be sent, or how long to wait before asking again
+func (b *Breaker) allow() time.Duration {
+	b.mu.Lock()
+	from := b.state
+
+	var wait time.Duration
+	switch b.state {
+	case Open:
+		wait = b.openedAt.Add(b.cooldown).Sub(b.now())
+		if wait <= 0 {
+			wait = 0
+			b.state = HalfOpen
+			b.trial = true
+		}
+	case HalfOpen:
+		if b.trial {
+			wait = halfOpenPoll
+		}
+		b.trial = true
+	}
+
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+	return wait
+}
+
+// record records the outcome of a request. Only the retryable errors are failures of the website.
+func (b *Breaker) record(err error) {
+	b.mu.Lock()
+	from := b.state
+
+	b.trial = false
+	if Retryable(err) {
+		b.failures++
+		if b.state == HalfOpen || b.failures >= b.threshold {
+			b.state = Open
+			b.openedAt = b.now()
+		}
+	} else {
+		b.failures = 0
+		b.state = Closed
+	}
+
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+}
+
+// abort releases the trial of the half-open state, without recording the outcome of the request
+func (b *Breaker) abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+func (b *Breaker) notify(from, to State) {
+	if from != to && b.onChange != nil {
+		b.onChange(from, to)
+	}
+}
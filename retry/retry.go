@@ -0,0 +1,224 @@
+// Package retry retries the requests to the FOSDEM website with an exponential backoff,
+// pausing them with a circuit breaker when the website keeps failing.
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Policy configures the retries of a request
+type Policy struct {
+	// MaxAttempts is the number of times a request is sent before giving up
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry, doubled at every retry up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// DefaultPolicy returns the Policy used if not configured
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts: 5,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    30 * time.Second,
+	}
+}
+
+// StatusError is returned for a response with an unexpected status code
+type StatusError struct {
+	StatusCode int
+	// RetryAfter is the wait asked by the server before the next attempt, if longer than the backoff
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return "unexpected status " + strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode)
+}
+
+// Retryable reports whether the request that returned the error can be sent again:
+// timeouts, refused or reset connections, 5xx and 429 responses. The other network errors,
+// as the invalid certificates or the unknown hosts, would fail again.
+func Retryable(err error) bool {
+	switch e := err.(type) {
+	case nil:
+		return false
+	case *StatusError:
+		return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+	case net.Error:
+		return e.Timeout() || connectionFailed(err)
+	}
+	return false
+}
+
+// connectionFailed reports whether the error is a connection refused or reset by the server
+func connectionFailed(err error) bool {
+	for {
+		switch e := err.(type) {
+		case *url.Error:
+			err = e.Err
+		case *net.OpError:
+			err = e.Err
+		case *os.SyscallError:
+			err = e.Err
+		case syscall.Errno:
+			return e == syscall.ECONNREFUSED || e == syscall.ECONNRESET
+		default:
+			return false
+		}
+	}
+}
+
+// Attempt is a failed attempt of a request, or the successful one after some failures
+type Attempt struct {
+	Target string
+	Number int
+	Err    error
+	// Wait is the wait before the next attempt, 0 if the request is not retried
+	Wait time.Duration
+}
+
+// Observer is notified of the attempts of the requests and of the changes of the circuit breaker
+type Observer interface {
+	Retried(a Attempt)
+	StateChanged(from, to State)
+}
+
+// Retrier sends the requests with the Policy, through the Breaker
+type Retrier struct {
+	policy  Policy
+	breaker *Breaker
+
+	mu       sync.Mutex
+	observer Observer
+	rand     *rand.Rand
+
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// New returns a Retrier. If breaker is nil the requests are never paused.
+func New(policy Policy, breaker *Breaker) *Retrier {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	r := &Retrier{
+		policy:  policy,
+		breaker: breaker,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		sleep:   sleep,
+	}
+	if breaker != nil {
+		breaker.onChange = r.stateChanged
+	}
+	return r
+}
+
+// SetObserver sets the Observer notified of the attempts and of the changes of the breaker
+func (r *Retrier) SetObserver(o Observer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.observer = o
+}
+
+// Do calls fn until it succeeds, it returns an error that is not retryable or the attempts are over.
+// The target identifies the request in the notified Attempts.
+// The waits are interrupted when the context is done, returning its error.
+func (r *Retrier) Do(ctx context.Context, target string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		if r.breaker != nil {
+			if err := r.breaker.wait(ctx, r.sleep); err != nil {
+				return err
+			}
+		}
+
+		err := fn()
+		if ctx.Err() != nil {
+			// the request was cancelled, it says nothing about the website
+			if r.breaker != nil {
+				r.breaker.abort()
+			}
+			return err
+		}
+		if r.breaker != nil {
+			r.breaker.record(err)
+		}
+
+		if err == nil || !Retryable(err) {
+			if attempt > 1 {
+				r.retried(Attempt{Target: target, Number: attempt, Err: err})
+			}
+			return err
+		}
+		if attempt >= r.policy.MaxAttempts {
+			r.retried(Attempt{Target: target, Number: attempt, Err: err})
+			return err
+		}
+
+		wait := r.backoff(attempt)
+		if e, ok := err.(*StatusError); ok && e.RetryAfter > wait {
+			wait = e.RetryAfter
+		}
+		r.retried(Attempt{Target: target, Number: attempt, Err: err, Wait: wait})
+		if err := r.sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// sleep waits for the duration, or until the context is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// backoff returns the wait after the attempt, between the half and the full exponential delay
+func (r *Retrier) backoff(attempt int) time.Duration {
+	delay := r.policy.BaseDelay
+	for i := 1; i < attempt && delay < r.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if r.policy.MaxDelay > 0 && delay > r.policy.MaxDelay {
+		delay = r.policy.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	r.mu.Lock()
+	jitter := time.Duration(r.rand.Int63n(int64(delay/2) + 1))
+	r.mu.Unlock()
+	return delay/2 + jitter
+}
+
+func (r *Retrier) retried(a Attempt) {
+	r.mu.Lock()
+	o := r.observer
+	r.mu.Unlock()
+	if o != nil {
+		o.Retried(a)
+	}
+}
+
+func (r *Retrier) stateChanged(from, to State) {
+	r.mu.Lock()
+	o := r.observer
+	r.mu.Unlock()
+	if o != nil {
+		o.StateChanged(from, to)
+	}
+}
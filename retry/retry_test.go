@@ -0,0 +1,202 @@
+package retry
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder struct {
+	attempts []Attempt
+	changes  [][2]State
+}
+
+func (r *recorder) Retried(a Attempt)           { r.attempts = append(r.attempts, a) }
+func (r *recorder) StateChanged(from, to State) { r.changes = append(r.changes, [2]State{from, to}) }
+
+func newTestRetrier(policy Policy, breaker *Breaker) (*Retrier, *recorder, *[]time.Duration) {
+	r := New(policy, breaker)
+	rec := &recorder{}
+	r.SetObserver(rec)
+	sleeps := make([]time.Duration, 0)
+	r.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return r, rec, &sleeps
+}
+
+func TestRetryable(t *testing.T) {
+	tt := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "nil", err: nil, expected: false},
+		{name: "server error", err: &StatusError{StatusCode: http.StatusBadGateway}, expected: true},
+		{name: "too many requests", err: &StatusError{StatusCode: http.StatusTooManyRequests}, expected: true},
+		{name: "not found", err: &StatusError{StatusCode: http.StatusNotFound}, expected: false},
+		{name: "connection refused", err: &url.Error{Op: "Get", Err: &net.OpError{Op: "dial", Err: &os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}}}, expected: true},
+		{name: "connection reset", err: &net.OpError{Op: "read", Err: &os.SyscallError{Syscall: "read", Err: syscall.ECONNRESET}}, expected: true},
+		{name: "timeout", err: &url.Error{Op: "Get", Err: &net.DNSError{Err: "i/o timeout", IsTimeout: true}}, expected: true},
+		{name: "unknown host", err: &url.Error{Op: "Get", Err: &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "fosdem.invalid"}}}, expected: false},
+		{name: "invalid certificate", err: &url.Error{Op: "Get", Err: x509.UnknownAuthorityError{}}, expected: false},
+		{name: "cancelled", err: &url.Error{Op: "Get", Err: context.Canceled}, expected: false},
+		{name: "other error", err: errors.New("main div not found"), expected: false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Retryable(tc.err))
+		})
+	}
+
+	// the error of a real connection refused
+	_, err := http.Get("http://127.0.0.1:1/")
+	assert.True(t, Retryable(err), err.Error())
+}
+
+func TestDo(t *testing.T) {
+	policy := Policy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	serverErr := &StatusError{StatusCode: http.StatusServiceUnavailable}
+
+	tt := []struct {
+		name          string
+		errs          []error
+		expectedErr   error
+		expectedCalls int
+		expectedSleep int
+	}{
+		{name: "success", errs: []error{nil}, expectedCalls: 1},
+		{name: "success after retries", errs: []error{serverErr, serverErr, nil}, expectedCalls: 3, expectedSleep: 2},
+		{name: "attempts over", errs: []error{serverErr, serverErr, serverErr}, expectedErr: serverErr, expectedCalls: 3, expectedSleep: 2},
+		{name: "not retryable", errs: []error{errors.New("bad page")}, expectedErr: errors.New("bad page"), expectedCalls: 1},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r, rec, sleeps := newTestRetrier(policy, nil)
+
+			calls := 0
+			err := r.Do(context.Background(), "https://fosdem.org/2018/schedule/xml", func() error {
+				err := tc.errs[calls]
+				calls++
+				return err
+			})
+
+			assert.Equal(t, tc.expectedErr, err)
+			assert.Equal(t, tc.expectedCalls, calls)
+			assert.Len(t, *sleeps, tc.expectedSleep)
+			if tc.expectedCalls > 1 {
+				assert.Len(t, rec.attempts, tc.expectedCalls)
+				assert.Equal(t, "https://fosdem.org/2018/schedule/xml", rec.attempts[0].Target)
+			} else {
+				assert.Empty(t, rec.attempts)
+			}
+		})
+	}
+}
+
+func TestDoRetryAfter(t *testing.T) {
+	r, _, sleeps := newTestRetrier(Policy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second}, nil)
+
+	// the wait asked by the server is used if longer than the backoff
+	errs := []error{
+		&StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Minute},
+		&StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Millisecond},
+		nil,
+	}
+	calls := 0
+	err := r.Do(context.Background(), "https://fosdem.org/2018/schedule/xml", func() error {
+		err := errs[calls]
+		calls++
+		return err
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, time.Minute, (*sleeps)[0])
+	assert.True(t, (*sleeps)[1] >= time.Second && (*sleeps)[1] <= 2*time.Second)
+}
+
+func TestBackoff(t *testing.T) {
+	r := New(Policy{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: 5 * time.Second}, nil)
+
+	for attempt, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		wait := r.backoff(attempt + 1)
+		assert.True(t, wait >= max/2 && wait <= max, "attempt %d: %s", attempt+1, wait)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2018, 2, 3, 10, 0, 0, 0, time.UTC)
+	breaker := NewBreaker(2, time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	r, rec, sleeps := newTestRetrier(Policy{MaxAttempts: 1}, breaker)
+	r.sleep = func(ctx context.Context, d time.Duration) error {
+		*sleeps = append(*sleeps, d)
+		now = now.Add(d)
+		return nil
+	}
+
+	serverErr := &StatusError{StatusCode: http.StatusInternalServerError}
+	fail := func() error { return serverErr }
+	succeed := func() error { return nil }
+
+	r.Do(context.Background(), "a", fail)
+	assert.Equal(t, Closed, breaker.State())
+	r.Do(context.Background(), "b", fail)
+	assert.Equal(t, Open, breaker.State())
+
+	// the next request waits for the cooldown, then closes the breaker
+	assert.Nil(t, r.Do(context.Background(), "c", succeed))
+	assert.Equal(t, []time.Duration{time.Minute}, *sleeps)
+	assert.Equal(t, Closed, breaker.State())
+
+	assert.Equal(t, [][2]State{{Closed, Open}, {Open, HalfOpen}, {HalfOpen, Closed}}, rec.changes)
+
+	// a failure while half-open opens it again
+	r.Do(context.Background(), "d", fail)
+	r.Do(context.Background(), "e", fail)
+	r.Do(context.Background(), "f", fail)
+	assert.Equal(t, Open, breaker.State())
+	assert.Equal(t, [][2]State{{Open, HalfOpen}, {HalfOpen, Open}}, rec.changes[4:])
+}
+
+func TestDoCancelled(t *testing.T) {
+	breaker := NewBreaker(1, time.Minute)
+	r := New(Policy{MaxAttempts: 5, BaseDelay: time.Hour}, breaker)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := r.Do(ctx, "a", func() error {
+		calls++
+		cancel()
+		return &net.OpError{Op: "dial", Err: context.Canceled}
+	})
+
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, calls)
+	// a cancelled request is not a failure of the website
+	assert.Equal(t, Closed, breaker.State())
+
+	// the wait for the backoff is interrupted by the cancellation
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	err = New(Policy{MaxAttempts: 5, BaseDelay: time.Hour}, nil).Do(ctx, "b", func() error {
+		return &StatusError{StatusCode: http.StatusBadGateway}
+	})
+	assert.Equal(t, context.Canceled, err)
+}
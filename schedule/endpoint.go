@@ -0,0 +1,152 @@
+package schedule
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/enrichman/api-fosdem/pentabarf"
+	"github.com/go-kit/kit/endpoint"
+)
+
+type scheduleService interface {
+	Snapshots(year int) ([]Snapshot, error)
+	Changes(year, from, to int) (*Changes, error)
+	Feed(f FeedFilter) (*Feed, error)
+}
+
+type snapshotsRequest struct {
+	year int
+}
+
+type snapshotsResponse struct {
+	Data []Snapshot `json:"data"`
+}
+
+func makeSnapshotsEndpoint(s scheduleService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(snapshotsRequest)
+		snapshots, err := s.Snapshots(req.year)
+		if err != nil {
+			return nil, err
+		}
+		return snapshotsResponse{snapshots}, nil
+	}
+}
+
+type changesRequest struct {
+	year int
+	from int
+	to   int
+}
+
+func makeChangesEndpoint(s scheduleService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(changesRequest)
+		return s.Changes(req.year, req.from, req.to)
+	}
+}
+
+type feedRequest struct {
+	filter          FeedFilter
+	ifNoneMatch     string
+	ifModifiedSince time.Time
+}
+
+type feedResponse struct {
+	xml          []byte
+	etag         string
+	lastModified time.Time
+	notModified  bool
+}
+
+func makeFeedEndpoint(s scheduleService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(feedRequest)
+		feed, err := s.Feed(req.filter)
+		if err != nil {
+			return nil, err
+		}
+
+		var buf bytes.Buffer
+		if err := pentabarf.Write(&buf, feed.Schedule); err != nil {
+			return nil, err
+		}
+
+		// the ETag is different for every filtered version of the schedule, and changes with
+		// every field of the events, as the time of the last change of the schedule
+		sum := sha256.Sum256(buf.Bytes())
+		res := feedResponse{
+			xml:          buf.Bytes(),
+			etag:         `"` + hex.EncodeToString(sum[:16]) + `"`,
+			lastModified: feed.LastModified.UTC().Truncate(time.Second),
+		}
+
+		// If-Modified-Since is ignored when If-None-Match is sent
+		if req.ifNoneMatch != "" {
+			res.notModified = matchETag(req.ifNoneMatch, res.etag)
+		} else if !req.ifModifiedSince.IsZero() && !res.lastModified.IsZero() {
+			res.notModified = !res.lastModified.After(req.ifModifiedSince)
+		}
+		return res, nil
+	}
+}
+
+// matchETag returns true if the etag is one of the list of the If-None-Match header
+func matchETag(ifNoneMatch, etag string) bool {
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == etag || t == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Snapshot is an indexed version of the schedule
+type Snapshot struct {
+	Year      int       `json:"year"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Changes contains the differences of the events between two snapshots
+type Changes struct {
+	Year            int           `json:"year"`
+	From            int           `json:"from"`
+	To              int           `json:"to"`
+	Added           []Event       `json:"added"`
+	Removed         []Event       `json:"removed"`
+	Cancelled       []Event       `json:"cancelled"`
+	Moved           []EventChange `json:"moved"`
+	Retitled        []EventChange `json:"retitled"`
+	SpeakersChanged []EventChange `json:"speakers_changed"`
+}
+
+// EventChange contains the old and the new version of a changed Event
+type EventChange struct {
+	Old Event `json:"old"`
+	New Event `json:"new"`
+}
+
+// Event maps the event
+type Event struct {
+	ID      int       `json:"id,omitempty"`
+	Slug    string    `json:"slug,omitempty"`
+	Title   string    `json:"title,omitempty"`
+	Track   string    `json:"track,omitempty"`
+	Type    string    `json:"type,omitempty"`
+	Room    string    `json:"room,omitempty"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Persons []Person  `json:"persons,omitempty"`
+}
+
+// Person is a speaker of an Event
+type Person struct {
+	ID   int    `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
@@ -0,0 +1,190 @@
+package schedule
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/enrichman/api-fosdem/pentabarf"
+	"github.com/enrichman/api-fosdem/store"
+)
+
+// ErrScheduleNotFound is returned when the schedule of the year was not indexed
+var ErrScheduleNotFound = notFoundError("schedule not found")
+
+type notFoundError string
+
+func (e notFoundError) Error() string {
+	return string(e)
+}
+
+// StatusCode is the status of the responses with the error
+func (e notFoundError) StatusCode() int {
+	return http.StatusNotFound
+}
+
+type snapshotFinder interface {
+	FindSnapshots(year int) ([]store.Snapshot, error)
+	FindSnapshot(year, version int) (*store.Snapshot, error)
+	FindLatestSnapshot(year int) (*store.Snapshot, error)
+}
+
+type scheduleFinder interface {
+	snapshotFinder
+	FindConference(year int) (*store.Conference, error)
+	FindEvents(f store.EventFilter) ([]store.Event, int, error)
+}
+
+// FeedFilter contains the parameters used to filter the events of the feed.
+// Empty fields are ignored, multiple values of the same field are in OR.
+type FeedFilter struct {
+	Year   int
+	IDs    []int
+	Days   []string // index or date of the day
+	Rooms  []string
+	Tracks []string
+	Types  []string
+}
+
+// Feed is the schedule of a year rebuilt from the stored events
+type Feed struct {
+	Schedule *pentabarf.Schedule
+	// LastModified is the time of the last change of the schedule, zero if unknown
+	LastModified time.Time
+}
+
+type Service struct {
+	scheduleFinder scheduleFinder
+}
+
+func NewService(scheduleFinder scheduleFinder) *Service {
+	return &Service{scheduleFinder}
+}
+
+// Snapshots returns the indexed versions of the schedule of the year
+func (s *Service) Snapshots(year int) ([]Snapshot, error) {
+	snapshotsFound, err := s.scheduleFinder.FindSnapshots(year)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]Snapshot, 0)
+	for _, sn := range snapshotsFound {
+		snapshots = append(snapshots, Snapshot{Year: sn.Year, Version: sn.Version, CreatedAt: sn.CreatedAt})
+	}
+	return snapshots, nil
+}
+
+// Changes returns the changes between two snapshots of the year.
+// If to is 0 the latest snapshot is used, if from is 0 the one before to.
+func (s *Service) Changes(year, from, to int) (*Changes, error) {
+	var newSnapshot *store.Snapshot
+	var err error
+	if to == 0 {
+		newSnapshot, err = s.scheduleFinder.FindLatestSnapshot(year)
+	} else {
+		newSnapshot, err = s.scheduleFinder.FindSnapshot(year, to)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if from == 0 {
+		from = newSnapshot.Version - 1
+	}
+	if from < 1 {
+		return nil, errors.New("no previous snapshot")
+	}
+
+	oldSnapshot, err := s.scheduleFinder.FindSnapshot(year, from)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := pentabarf.Diff(store.BuildSchedule(oldSnapshot.Events), store.BuildSchedule(newSnapshot.Events))
+
+	return &Changes{
+		Year:            year,
+		From:            oldSnapshot.Version,
+		To:              newSnapshot.Version,
+		Added:           convertEvents(diff.Added),
+		Removed:         convertEvents(diff.Removed),
+		Cancelled:       convertEvents(diff.Cancelled),
+		Moved:           convertEventChanges(diff.Moved),
+		Retitled:        convertEventChanges(diff.Retitled),
+		SpeakersChanged: convertEventChanges(diff.SpeakersChanged),
+	}, nil
+}
+
+// Feed returns the schedule of the year, rebuilt from the stored events, with only the events matching the filter
+func (s *Service) Feed(f FeedFilter) (*Feed, error) {
+	// the times of the schedule are local to the conference, as in the original one
+	location, err := time.LoadLocation("Europe/Brussels")
+	if err != nil {
+		return nil, err
+	}
+
+	conf, err := s.scheduleFinder.FindConference(f.Year)
+	if err == store.ErrNotFound {
+		return nil, ErrScheduleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	conf.StartDate = conf.StartDate.In(location)
+	conf.EndDate = conf.EndDate.In(location)
+
+	events, _, err := s.scheduleFinder.FindEvents(store.EventFilter{
+		Years:  []int{f.Year},
+		IDs:    f.IDs,
+		Days:   f.Days,
+		Rooms:  f.Rooms,
+		Tracks: f.Tracks,
+		Types:  f.Types,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range events {
+		events[i].Start = events[i].Start.In(location)
+		events[i].End = events[i].End.In(location)
+	}
+
+	feed := &Feed{Schedule: store.BuildSchedule(events), LastModified: conf.UpdatedAt}
+	feed.Schedule.Conference = conf.ToPentabarf()
+	return feed, nil
+}
+
+func convertEvents(events []*pentabarf.Event) []Event {
+	converted := make([]Event, 0)
+	for _, e := range events {
+		converted = append(converted, convertEvent(e))
+	}
+	return converted
+}
+
+func convertEventChanges(changes []*pentabarf.EventChange) []EventChange {
+	converted := make([]EventChange, 0)
+	for _, c := range changes {
+		converted = append(converted, EventChange{Old: convertEvent(c.Old), New: convertEvent(c.New)})
+	}
+	return converted
+}
+
+func convertEvent(e *pentabarf.Event) Event {
+	event := Event{
+		ID:      e.ID,
+		Slug:    e.Slug,
+		Title:   e.Title,
+		Track:   e.Track,
+		Type:    e.Type,
+		Room:    e.Room,
+		Start:   e.Start,
+		End:     e.Start.Add(e.Duration),
+		Persons: make([]Person, 0),
+	}
+	for _, p := range e.Persons {
+		event.Persons = append(event.Persons, Person{ID: p.ID, Name: p.Name})
+	}
+	return event
+}
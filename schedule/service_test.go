@@ -0,0 +1,104 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/enrichman/api-fosdem/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// updatedAt is the time of the last change of the test schedule
+var updatedAt = time.Date(2018, 1, 20, 10, 0, 0, 0, time.UTC)
+
+// newTestStore returns a store with the conference of the 2018 and four events of two days
+func newTestStore(t *testing.T) *store.MemoryStore {
+	location, _ := time.LoadLocation("Europe/Brussels")
+	ms := store.NewMemoryStore()
+
+	assert.Nil(t, ms.SaveConference(store.Conference{
+		Year:      2018,
+		Title:     "FOSDEM 2018",
+		City:      "Brussels",
+		StartDate: time.Date(2018, 2, 3, 0, 0, 0, 0, location),
+		EndDate:   time.Date(2018, 2, 4, 0, 0, 0, 0, location),
+		Days:      2,
+		DayChange: 9 * time.Hour,
+		UpdatedAt: updatedAt,
+	}))
+
+	events := []struct {
+		id                int
+		day               int
+		room, track, kind string
+	}{
+		{1, 1, "H.1308", "Go", "devroom"},
+		{2, 1, "H.2214", "Rust", "devroom"},
+		{3, 2, "H.1308", "Go", "lightningtalk"},
+		{4, 2, "Janson", "Keynotes", "keynote"},
+	}
+	for i, e := range events {
+		date := time.Date(2018, 2, 2+e.day, 10+i, 0, 0, 0, location)
+		assert.Nil(t, ms.SaveEvent(store.Event{
+			ID:       e.id,
+			Year:     2018,
+			Slug:     "event_" + string(rune('a'+i)),
+			Title:    "Event",
+			Track:    e.track,
+			Type:     e.kind,
+			Room:     e.room,
+			Day:      e.day,
+			Date:     date.Format("2006-01-02"),
+			Start:    date,
+			End:      date.Add(30 * time.Minute),
+			Duration: 30 * time.Minute,
+			Persons:  []store.Person{{ID: e.id, Name: "Speaker"}},
+		}))
+	}
+	return ms
+}
+
+func TestFeed(t *testing.T) {
+	s := NewService(newTestStore(t))
+
+	tt := []struct {
+		name        string
+		filter      FeedFilter
+		expectedIDs []int
+		expectedErr error
+	}{
+		{name: "all", filter: FeedFilter{Year: 2018}, expectedIDs: []int{1, 2, 3, 4}},
+		{name: "track", filter: FeedFilter{Year: 2018, Tracks: []string{"Go"}}, expectedIDs: []int{1, 3}},
+		{name: "tracks", filter: FeedFilter{Year: 2018, Tracks: []string{"Go", "Rust"}}, expectedIDs: []int{1, 2, 3}},
+		{name: "room", filter: FeedFilter{Year: 2018, Rooms: []string{"Janson"}}, expectedIDs: []int{4}},
+		{name: "day index", filter: FeedFilter{Year: 2018, Days: []string{"1"}}, expectedIDs: []int{1, 2}},
+		{name: "day date", filter: FeedFilter{Year: 2018, Days: []string{"2018-02-04"}}, expectedIDs: []int{3, 4}},
+		{name: "type", filter: FeedFilter{Year: 2018, Types: []string{"devroom"}}, expectedIDs: []int{1, 2}},
+		{name: "id", filter: FeedFilter{Year: 2018, IDs: []int{2, 4}}, expectedIDs: []int{2, 4}},
+		{name: "in AND", filter: FeedFilter{Year: 2018, Tracks: []string{"Go"}, Days: []string{"2"}}, expectedIDs: []int{3}},
+		{name: "no events", filter: FeedFilter{Year: 2018, Tracks: []string{"Unknown"}}, expectedIDs: []int{}},
+		{name: "unknown year", filter: FeedFilter{Year: 2010}, expectedErr: ErrScheduleNotFound},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			feed, err := s.Feed(tc.filter)
+			assert.Equal(t, tc.expectedErr, err)
+			if tc.expectedErr != nil {
+				return
+			}
+			schedule := feed.Schedule
+			assert.Equal(t, updatedAt, feed.LastModified)
+
+			ids := make([]int, 0)
+			for _, e := range schedule.GetAllEvents() {
+				ids = append(ids, e.ID)
+			}
+			assert.Equal(t, tc.expectedIDs, ids)
+
+			// the conference is the same for every filter
+			assert.Equal(t, "Brussels", schedule.Conference.City)
+			assert.Equal(t, "09:00:00", schedule.Conference.DayChangeStr)
+		})
+	}
+}
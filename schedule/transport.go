@@ -0,0 +1,139 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+)
+
+// MakeScheduleHandler setup the handlers on the /api/v1/schedule route
+func MakeScheduleHandler(s scheduleService) http.Handler {
+	r := mux.NewRouter().StrictSlash(true)
+
+	snapshotsHandler := kithttp.NewServer(
+		makeSnapshotsEndpoint(s),
+		decodeSnapshots,
+		encodeResponse,
+	)
+
+	changesHandler := kithttp.NewServer(
+		makeChangesEndpoint(s),
+		decodeChanges,
+		encodeResponse,
+	)
+
+	feedHandler := kithttp.NewServer(
+		makeFeedEndpoint(s),
+		decodeFeed,
+		encodeFeed,
+	)
+
+	r.Handle("/api/v1/schedule/{year:[0-9]+}.xml", feedHandler).Methods(http.MethodGet)
+	r.Handle("/api/v1/schedule/{year}/snapshots", snapshotsHandler).Methods(http.MethodGet)
+	r.Handle("/api/v1/schedule/{year}/changes", changesHandler).Methods(http.MethodGet)
+
+	return r
+}
+
+func decodeYear(r *http.Request) (int, error) {
+	year, err := strconv.Atoi(mux.Vars(r)["year"])
+	if err != nil {
+		return 0, errors.New("wrong year")
+	}
+	return year, nil
+}
+
+func decodeSnapshots(_ context.Context, r *http.Request) (interface{}, error) {
+	year, err := decodeYear(r)
+	if err != nil {
+		return nil, err
+	}
+	return snapshotsRequest{year}, nil
+}
+
+func decodeChanges(_ context.Context, r *http.Request) (interface{}, error) {
+	var err error
+	var req changesRequest
+
+	req.year, err = decodeYear(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if from := r.FormValue("from"); from != "" {
+		req.from, err = strconv.Atoi(from)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if to := r.FormValue("to"); to != "" {
+		req.to, err = strconv.Atoi(to)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+func decodeFeed(_ context.Context, r *http.Request) (interface{}, error) {
+	var err error
+	var req feedRequest
+
+	if err = r.ParseForm(); err != nil {
+		return nil, err
+	}
+
+	req.filter.Year, err = decodeYear(r)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range r.Form["id"] {
+		for _, id := range strings.Split(v, ",") {
+			i, err := strconv.Atoi(id)
+			if err != nil {
+				return nil, err
+			}
+			req.filter.IDs = append(req.filter.IDs, i)
+		}
+	}
+
+	// names can contain commas, so multiple values are passed repeating the parameter
+	req.filter.Days = r.Form["day"]
+	req.filter.Rooms = r.Form["room"]
+	req.filter.Tracks = r.Form["track"]
+	req.filter.Types = r.Form["type"]
+
+	req.ifNoneMatch = r.Header.Get("If-None-Match")
+	// an invalid date is ignored, as the header was not sent
+	if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil {
+		req.ifModifiedSince = since
+	}
+	return req, nil
+}
+
+func encodeFeed(_ context.Context, w http.ResponseWriter, res interface{}) error {
+	feed := res.(feedResponse)
+	w.Header().Set("ETag", feed.etag)
+	if !feed.lastModified.IsZero() {
+		w.Header().Set("Last-Modified", feed.lastModified.Format(http.TimeFormat))
+	}
+	if feed.notModified {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	_, err := w.Write(feed.xml)
+	return err
+}
+
+func encodeResponse(_ context.Context, w http.ResponseWriter, res interface{}) error {
+	return json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,123 @@
+package schedule
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/enrichman/api-fosdem/pentabarf"
+	"github.com/stretchr/testify/assert"
+)
+
+func getFeed(t *testing.T, handler http.Handler, url string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestFeedHandler(t *testing.T) {
+	handler := MakeScheduleHandler(NewService(newTestStore(t)))
+
+	res := getFeed(t, handler, "/api/v1/schedule/2018.xml?track=Go&track=Rust&id=1,2,3", nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "application/xml; charset=utf-8", res.Header().Get("Content-Type"))
+	assert.Equal(t, "Sat, 20 Jan 2018 10:00:00 GMT", res.Header().Get("Last-Modified"))
+	etag := res.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	schedule, err := pentabarf.Parse(res.Body)
+	assert.Nil(t, err)
+	assert.Len(t, schedule.GetAllEvents(), 3)
+
+	// the ETag is stable, and different for every filter
+	assert.Equal(t, etag, getFeed(t, handler, "/api/v1/schedule/2018.xml?track=Go&track=Rust&id=1,2,3", nil).Header().Get("ETag"))
+	assert.NotEqual(t, etag, getFeed(t, handler, "/api/v1/schedule/2018.xml?track=Go", nil).Header().Get("ETag"))
+
+	tt := []struct {
+		name           string
+		url            string
+		header         http.Header
+		expectedStatus int
+	}{
+		{
+			name:           "matching ETag",
+			url:            "/api/v1/schedule/2018.xml?track=Go&track=Rust&id=1,2,3",
+			header:         http.Header{"If-None-Match": {etag}},
+			expectedStatus: http.StatusNotModified,
+		},
+		{
+			name:           "one of the ETags, weak",
+			url:            "/api/v1/schedule/2018.xml?track=Go&track=Rust&id=1,2,3",
+			header:         http.Header{"If-None-Match": {`"other", W/` + etag}},
+			expectedStatus: http.StatusNotModified,
+		},
+		{
+			name:           "ETag of another filter",
+			url:            "/api/v1/schedule/2018.xml?track=Go",
+			header:         http.Header{"If-None-Match": {etag}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "If-Modified-Since ignored with If-None-Match",
+			url:            "/api/v1/schedule/2018.xml?track=Go",
+			header:         http.Header{"If-None-Match": {etag}, "If-Modified-Since": {"Fri, 01 Jan 2100 00:00:00 GMT"}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "not modified since the last change",
+			url:            "/api/v1/schedule/2018.xml",
+			header:         http.Header{"If-Modified-Since": {"Sat, 20 Jan 2018 10:00:00 GMT"}},
+			expectedStatus: http.StatusNotModified,
+		},
+		{
+			name:           "not modified since a later time",
+			url:            "/api/v1/schedule/2018.xml?track=Go",
+			header:         http.Header{"If-Modified-Since": {"Sun, 21 Jan 2018 10:00:00 GMT"}},
+			expectedStatus: http.StatusNotModified,
+		},
+		{
+			name:           "modified since",
+			url:            "/api/v1/schedule/2018.xml",
+			header:         http.Header{"If-Modified-Since": {"Sat, 20 Jan 2018 09:59:59 GMT"}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "invalid If-Modified-Since",
+			url:            "/api/v1/schedule/2018.xml",
+			header:         http.Header{"If-Modified-Since": {"yesterday"}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "unknown year",
+			url:            "/api/v1/schedule/2010.xml",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			res := getFeed(t, handler, tc.url, tc.header)
+			assert.Equal(t, tc.expectedStatus, res.Code)
+			if tc.expectedStatus == http.StatusNotModified {
+				if tc.header.Get("If-None-Match") != "" {
+					assert.Equal(t, etag, res.Header().Get("ETag"))
+				}
+				assert.Equal(t, "Sat, 20 Jan 2018 10:00:00 GMT", res.Header().Get("Last-Modified"))
+				assert.Empty(t, strings.TrimSpace(res.Body.String()))
+			}
+		})
+	}
+}
+
+func Test_matchETag(t *testing.T) {
+	assert.True(t, matchETag(`"abc"`, `"abc"`))
+	assert.True(t, matchETag(`"xyz", W/"abc"`, `"abc"`))
+	assert.True(t, matchETag(`*`, `"abc"`))
+	assert.False(t, matchETag(`"ab"`, `"abc"`))
+	assert.False(t, matchETag(`abc`, `"abc"`))
+}
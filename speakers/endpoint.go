@@ -8,7 +8,7 @@ import (
 
 type speakerService interface {
 	FindByID(int, int) (*Speaker, error)
-	Find(limit, offset int, name string, years []int) ([]Speaker, int, error)
+	Find(f Filter) ([]Speaker, int, error)
 }
 
 func makeSpeakerGetterEndpoint(finder speakerService) endpoint.Endpoint {
@@ -18,13 +18,6 @@ func makeSpeakerGetterEndpoint(finder speakerService) endpoint.Endpoint {
 	}
 }
 
-type findRequest struct {
-	limit  int
-	offset int
-	slug   string
-	years  []int
-}
-
 type findResponse struct {
 	Count int       `json:"count"`
 	Data  []Speaker `json:"data"`
@@ -50,8 +43,8 @@ type Link struct {
 
 func makeSpeakerFinderEndpoint(finder speakerService) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
-		req := request.(findRequest)
-		speakers, count, err := finder.Find(req.limit, req.offset, req.slug, req.years)
+		req := request.(Filter)
+		speakers, count, err := finder.Find(req)
 		if err != nil {
 			return nil, err
 		}
@@ -1,10 +1,26 @@
 package speakers
 
-import "github.com/enrichman/api-fosdem/store"
+import (
+	"time"
+
+	"github.com/enrichman/api-fosdem/store"
+)
 
 type speakerFinder interface {
 	FindByID(int, int) (*store.Speaker, error)
-	Find(limit, offset int, name string, years []int) ([]store.Speaker, int, error)
+	Find(f store.SpeakerFilter) ([]store.Speaker, int, error)
+	LatestYear() (int, error)
+}
+
+// Filter contains the parameters used to search through the speakers.
+// Empty fields are ignored, multiple years are in OR.
+type Filter struct {
+	Limit  int
+	Offset int
+	Slug   string
+	Match  string // exact, prefix, contains (the default) or regex
+	Name   string
+	Years  []int
 }
 
 type Service struct {
@@ -15,7 +31,16 @@ func NewService(speakerFinder speakerFinder) *Service {
 	return &Service{speakerFinder}
 }
 
+// FindByID returns the speaker of the year, or of the latest edition if year is 0
 func (s *Service) FindByID(id, year int) (*Speaker, error) {
+	if year == 0 {
+		latest, err := s.latestYear()
+		if err != nil {
+			return nil, err
+		}
+		year = latest
+	}
+
 	storeSpeaker, err := s.speakerFinder.FindByID(id, year)
 	if err != nil {
 		return nil, err
@@ -24,8 +49,24 @@ func (s *Service) FindByID(id, year int) (*Speaker, error) {
 	return &speaker, nil
 }
 
-func (s *Service) Find(limit, offset int, name string, years []int) ([]Speaker, int, error) {
-	speakersFound, count, err := s.speakerFinder.Find(limit, offset, name, years)
+// Find returns the speakers matching the filter, of the latest edition if no years are passed
+func (s *Service) Find(f Filter) ([]Speaker, int, error) {
+	if len(f.Years) == 0 {
+		latest, err := s.latestYear()
+		if err != nil {
+			return nil, -1, err
+		}
+		f.Years = []int{latest}
+	}
+
+	speakersFound, count, err := s.speakerFinder.Find(store.SpeakerFilter{
+		Limit:  f.Limit,
+		Offset: f.Offset,
+		Slug:   f.Slug,
+		Match:  f.Match,
+		Name:   f.Name,
+		Years:  f.Years,
+	})
 	if err != nil {
 		return nil, -1, err
 	}
@@ -38,6 +79,15 @@ func (s *Service) Find(limit, offset int, name string, years []int) ([]Speaker,
 	return speakers, count, nil
 }
 
+// latestYear returns the last indexed edition, or the current year if nothing was indexed yet
+func (s *Service) latestYear() (int, error) {
+	year, err := s.speakerFinder.LatestYear()
+	if err == store.ErrNotFound {
+		return time.Now().Year(), nil
+	}
+	return year, err
+}
+
 func convertSpeaker(s store.Speaker) Speaker {
 	speaker := Speaker{
 		ID:           s.ID,
@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/enrichman/api-fosdem/store"
 	kithttp "github.com/go-kit/kit/transport/http"
 	"github.com/gorilla/mux"
 )
@@ -41,7 +42,7 @@ type getSpeakerByIDRequest struct {
 
 func decodeSpeakerGetter(_ context.Context, r *http.Request) (interface{}, error) {
 	var err error
-	req := getSpeakerByIDRequest{year: 2018}
+	var req getSpeakerByIDRequest
 
 	vars := mux.Vars(r)
 	req.id, err = strconv.Atoi(vars["id"])
@@ -64,40 +65,40 @@ func encodeSpeakerGetter(_ context.Context, w http.ResponseWriter, res interface
 
 func decodeSpeakerFinder(_ context.Context, r *http.Request) (interface{}, error) {
 	var err error
-	var req findRequest
+	var req Filter
 
+	// the wrong parameters are answered with 400
 	if limit := r.FormValue("limit"); limit != "" {
-		req.limit, err = strconv.Atoi(limit)
-		if err != nil {
-			return nil, err
+		req.Limit, err = strconv.Atoi(limit)
+		if err != nil || req.Limit < 0 {
+			return nil, store.InvalidFilterError{Reason: "wrong limit"}
 		}
 	}
 
 	if offset := r.FormValue("offset"); offset != "" {
-		req.offset, err = strconv.Atoi(offset)
-		if err != nil {
-			return nil, err
+		req.Offset, err = strconv.Atoi(offset)
+		if err != nil || req.Offset < 0 {
+			return nil, store.InvalidFilterError{Reason: "wrong offset"}
 		}
 	}
 
-	req.years = make([]int, 0)
+	req.Years = make([]int, 0)
 	if year := r.FormValue("year"); year != "" {
 		for _, y := range strings.Split(year, ",") {
 			yearInt, err := strconv.Atoi(y)
 			if err != nil {
-				return nil, err
+				return nil, store.InvalidFilterError{Reason: "wrong year"}
 			}
-			req.years = append(req.years, yearInt)
+			req.Years = append(req.Years, yearInt)
 		}
 	}
-	if len(req.years) == 0 {
-		req.years = append(req.years, 2018)
-	}
 
-	req.slug = r.FormValue("slug")
+	req.Slug = r.FormValue("slug")
+	req.Match = r.FormValue("match")
+	req.Name = r.FormValue("name")
 
-	if req.limit == 0 || req.limit > 100 {
-		req.limit = 100
+	if req.Limit == 0 || req.Limit > 100 {
+		req.Limit = 100
 	}
 	return req, nil
 }
@@ -0,0 +1,36 @@
+package speakers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/enrichman/api-fosdem/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpeakerFinderHandler(t *testing.T) {
+	ms := store.NewMemoryStore()
+	assert.Nil(t, ms.Save(store.Speaker{ID: 1, Slug: "mario_rossi", Name: "Mario Rossi", Year: 2018}))
+	handler := MakeSpeakersHandler(NewService(ms))
+
+	tt := []struct {
+		name           string
+		url            string
+		expectedStatus int
+	}{
+		{name: "valid", url: "/api/v1/speakers?limit=10&offset=0&year=2018", expectedStatus: http.StatusOK},
+		{name: "negative limit", url: "/api/v1/speakers?limit=-1", expectedStatus: http.StatusBadRequest},
+		{name: "negative offset", url: "/api/v1/speakers?offset=-1", expectedStatus: http.StatusBadRequest},
+		{name: "wrong limit", url: "/api/v1/speakers?limit=ten", expectedStatus: http.StatusBadRequest},
+		{name: "wrong year", url: "/api/v1/speakers?year=2018,last", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.url, nil))
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,574 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// the buckets of the BoltStore. The keys of the primary buckets start with the year,
+// so they are also the index on the year.
+var (
+	speakerBucket         = []byte("speakers")
+	speakerSlugBucket     = []byte("speakers_by_slug")
+	speakerPageBucket     = []byte("speakers_by_page")
+	conferenceBucket      = []byte("conferences")
+	dayBucket             = []byte("days")
+	roomBucket            = []byte("rooms")
+	trackBucket           = []byte("tracks")
+	eventBucket           = []byte("events")
+	eventTrackBucket      = []byte("events_by_track")
+	eventRoomBucket       = []byte("events_by_room")
+	snapshotBucket        = []byte("snapshots")
+	reportBucket          = []byte("reports")
+	reportStartedAtBucket = []byte("reports_by_startedat")
+)
+
+// BoltStore can save and retrieve everything in an embedded bbolt database, a single file
+type BoltStore struct {
+	db *bolt.DB
+}
+
+var _ Store = (*BoltStore)(nil)
+
+// NewBoltStore opens, or creates, the database in the file at path
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{
+			speakerBucket, speakerSlugBucket, speakerPageBucket,
+			conferenceBucket, dayBucket, roomBucket, trackBucket,
+			eventBucket, eventTrackBucket, eventRoomBucket,
+			snapshotBucket, reportBucket, reportStartedAtBucket,
+		} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db}, nil
+}
+
+// Close closes the database
+func (bs *BoltStore) Close() error {
+	return bs.db.Close()
+}
+
+// key joins the parts in a key sorted as the parts: the numbers are big endian,
+// the strings are terminated by a 0 byte
+func key(parts ...interface{}) []byte {
+	var buf bytes.Buffer
+	for _, p := range parts {
+		switch v := p.(type) {
+		case int:
+			binary.Write(&buf, binary.BigEndian, uint64(v))
+		case int64:
+			binary.Write(&buf, binary.BigEndian, uint64(v))
+		case string:
+			buf.WriteString(v)
+			buf.WriteByte(0)
+		}
+	}
+	return buf.Bytes()
+}
+
+// suffix returns the last n bytes of an index key, the primary key of the indexed value
+func suffix(k []byte, n int) []byte {
+	return k[len(k)-n:]
+}
+
+// the length of the primary keys made of two numbers
+const numbersKeyLen = 16
+
+func put(b *bolt.Bucket, k []byte, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.Put(k, data)
+}
+
+// get decodes the value of the key, returning ErrNotFound if missing
+func get(b *bolt.Bucket, k []byte, v interface{}) error {
+	data := b.Get(k)
+	if data == nil {
+		return ErrNotFound
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Save a speaker of the passed year
+func (bs *BoltStore) Save(s Speaker) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(speakerBucket)
+		k := key(s.Year, s.ID)
+
+		var old Speaker
+		err := get(b, k, &old)
+		if err != nil && err != ErrNotFound {
+			return err
+		}
+		if err == nil {
+			if err := tx.Bucket(speakerSlugBucket).Delete(slugKey(old)); err != nil {
+				return err
+			}
+			if err := tx.Bucket(speakerPageBucket).Delete(key(old.ProfilePage, old.Year, old.ID)); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Bucket(speakerSlugBucket).Put(slugKey(s), nil); err != nil {
+			return err
+		}
+		if err := tx.Bucket(speakerPageBucket).Put(key(s.ProfilePage, s.Year, s.ID), nil); err != nil {
+			return err
+		}
+		return put(b, k, s)
+	})
+}
+
+// FindByID find a Speaker from its ID
+func (bs *BoltStore) FindByID(ID, year int) (*Speaker, error) {
+	var s Speaker
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		return get(tx.Bucket(speakerBucket), key(year, ID), &s)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// FindSpeakerByProfilePage find a Speaker from its profile page (unique for every year)
+func (bs *BoltStore) FindSpeakerByProfilePage(profilePage string) (*Speaker, error) {
+	var s Speaker
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		prefix := key(profilePage)
+		k, _ := tx.Bucket(speakerPageBucket).Cursor().Seek(prefix)
+		if k == nil || !bytes.HasPrefix(k, prefix) || len(k) != len(prefix)+numbersKeyLen {
+			return ErrNotFound
+		}
+		return get(tx.Bucket(speakerBucket), suffix(k, numbersKeyLen), &s)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// slugKey is the key of the speaker in the slug index. The slug is lowercase, as it is searched
+// ignoring the case.
+func slugKey(s Speaker) []byte {
+	return key(strings.ToLower(s.Slug), s.Year, s.ID)
+}
+
+// Find find a list of Speakers based on the passed filter.
+// The exact and prefix slugs are sought on the slug index, the years on the primary keys, and
+// the other slugs are matched on the whole slug index, so only the speakers found are decoded.
+func (bs *BoltStore) Find(f SpeakerFilter) ([]Speaker, int, error) {
+	patterns, err := compileSlug(f)
+	if err != nil {
+		return nil, 0, err
+	}
+	words := f.nameWords()
+	slug := strings.ToLower(strings.TrimSpace(f.Slug))
+
+	speakersFound := make([]Speaker, 0)
+	err = bs.db.View(func(tx *bolt.Tx) error {
+		speakers := tx.Bucket(speakerBucket)
+		match := func(primary []byte) error {
+			year := int(binary.BigEndian.Uint64(primary))
+			if len(f.Years) > 0 && !containsInt(f.Years, year) {
+				return nil
+			}
+			var s Speaker
+			if err := get(speakers, primary, &s); err != nil {
+				return err
+			}
+			if matchAll(patterns, s.Slug) && matchName(words, s.Name) {
+				speakersFound = append(speakersFound, s)
+			}
+			return nil
+		}
+
+		switch {
+		case slug != "" && f.Match == MatchExact:
+			return scanIndex(tx.Bucket(speakerSlugBucket), []string{slug}, match)
+		case slug != "" && f.Match == MatchPrefix:
+			// the prefix without the terminator, to find the longer slugs
+			c := tx.Bucket(speakerSlugBucket).Cursor()
+			prefix := []byte(slug)
+			for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+				if err := match(suffix(k, numbersKeyLen)); err != nil {
+					return err
+				}
+			}
+			return nil
+		case len(f.Years) > 0:
+			for _, year := range uniqueInts(f.Years) {
+				c := speakers.Cursor()
+				prefix := key(year)
+				for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+					if err := match(k); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}
+		return tx.Bucket(speakerSlugBucket).ForEach(func(k, _ []byte) error {
+			// the slug without the terminator is matched before decoding the speaker
+			if !matchAll(patterns, string(k[:len(k)-numbersKeyLen-1])) {
+				return nil
+			}
+			return match(suffix(k, numbersKeyLen))
+		})
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sortSpeakers(speakersFound)
+	start, end := page(len(speakersFound), f.Offset, f.Limit)
+	return speakersFound[start:end], len(speakersFound), nil
+}
+
+// SaveConference saves the main information of the conference of the passed year
+func (bs *BoltStore) SaveConference(conf Conference) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return put(tx.Bucket(conferenceBucket), key(conf.Year), conf)
+	})
+}
+
+// LatestYear returns the year of the last indexed edition of the conference
+func (bs *BoltStore) LatestYear() (int, error) {
+	conf, err := bs.FindLatestConference()
+	if err != nil {
+		return 0, err
+	}
+	return conf.Year, nil
+}
+
+// FindLatestConference returns the last indexed edition of the conference
+func (bs *BoltStore) FindLatestConference() (*Conference, error) {
+	var conf Conference
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		k, v := tx.Bucket(conferenceBucket).Cursor().Last()
+		if k == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(v, &conf)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+// FindConference returns the edition of the conference of the year
+func (bs *BoltStore) FindConference(year int) (*Conference, error) {
+	var conf Conference
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		return get(tx.Bucket(conferenceBucket), key(year), &conf)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+// SaveDay saves a day of the conference
+func (bs *BoltStore) SaveDay(d Day) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return put(tx.Bucket(dayBucket), key(d.Year, d.Index), d)
+	})
+}
+
+// SaveRoom saves a room of the conference
+func (bs *BoltStore) SaveRoom(r Room) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return put(tx.Bucket(roomBucket), key(r.Year, r.Name), r)
+	})
+}
+
+// SaveTrack saves a track of the conference
+func (bs *BoltStore) SaveTrack(t Track) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return put(tx.Bucket(trackBucket), key(t.Year, t.Name), t)
+	})
+}
+
+// SaveEvent saves an event of the passed year
+func (bs *BoltStore) SaveEvent(e Event) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(eventBucket)
+		k := key(e.Year, e.ID)
+
+		var old Event
+		err := get(b, k, &old)
+		if err != nil && err != ErrNotFound {
+			return err
+		}
+		if err == nil {
+			if err := tx.Bucket(eventTrackBucket).Delete(key(old.Track, old.Year, old.ID)); err != nil {
+				return err
+			}
+			if err := tx.Bucket(eventRoomBucket).Delete(key(old.Room, old.Year, old.ID)); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Bucket(eventTrackBucket).Put(key(e.Track, e.Year, e.ID), nil); err != nil {
+			return err
+		}
+		if err := tx.Bucket(eventRoomBucket).Put(key(e.Room, e.Year, e.ID), nil); err != nil {
+			return err
+		}
+		return put(b, k, e)
+	})
+}
+
+// FindEventByID find an Event from its ID
+func (bs *BoltStore) FindEventByID(ID, year int) (*Event, error) {
+	var e Event
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		return get(tx.Bucket(eventBucket), key(year, ID), &e)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// FindEvents find a list of Events based on the passed filter.
+// The candidates are read from the index of the rooms, of the tracks or of the years, if filtered.
+func (bs *BoltStore) FindEvents(f EventFilter) ([]Event, int, error) {
+	eventsFound := make([]Event, 0)
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		events := tx.Bucket(eventBucket)
+		match := func(primary []byte) error {
+			var e Event
+			if err := get(events, primary, &e); err != nil {
+				return err
+			}
+			if matchEvent(f, e) {
+				eventsFound = append(eventsFound, e)
+			}
+			return nil
+		}
+
+		switch {
+		case len(f.Rooms) > 0:
+			return scanIndex(tx.Bucket(eventRoomBucket), f.Rooms, match)
+		case len(f.Tracks) > 0:
+			return scanIndex(tx.Bucket(eventTrackBucket), f.Tracks, match)
+		case len(f.Years) > 0:
+			for _, year := range uniqueInts(f.Years) {
+				c := events.Cursor()
+				prefix := key(year)
+				for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+					if err := match(k); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}
+		return events.ForEach(func(k, _ []byte) error { return match(k) })
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sortEvents(eventsFound)
+	start, end := page(len(eventsFound), f.Offset, f.Limit)
+	return eventsFound[start:end], len(eventsFound), nil
+}
+
+// DeleteEvents deletes the events of the year, except the ones with the IDs to keep,
+// and their keys in the indexes of the tracks and of the rooms
+func (bs *BoltStore) DeleteEvents(year int, keepIDs []int) error {
+	keep := make(map[int]bool)
+	for _, ID := range keepIDs {
+		keep[ID] = true
+	}
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(eventBucket)
+		deleted := make([]Event, 0)
+		c := b.Cursor()
+		prefix := key(year)
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var e Event
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			if !keep[e.ID] {
+				deleted = append(deleted, e)
+			}
+		}
+
+		// the keys are deleted after the scan, that would skip some keys deleting with the cursor
+		for _, e := range deleted {
+			if err := tx.Bucket(eventTrackBucket).Delete(key(e.Track, e.Year, e.ID)); err != nil {
+				return err
+			}
+			if err := tx.Bucket(eventRoomBucket).Delete(key(e.Room, e.Year, e.ID)); err != nil {
+				return err
+			}
+			if err := b.Delete(key(e.Year, e.ID)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// scanIndex calls f with the primary keys indexed by the values, once for every value
+func scanIndex(index *bolt.Bucket, values []string, f func(primary []byte) error) error {
+	for _, v := range uniqueStrings(values) {
+		c := index.Cursor()
+		prefix := key(v)
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			// a longer value with the same prefix, i.e. "Go" and "Go\x00..." can't be confused thanks to the terminator
+			if len(k) != len(prefix)+numbersKeyLen {
+				continue
+			}
+			if err := f(suffix(k, numbersKeyLen)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SaveSnapshot saves a version of the events of the year
+func (bs *BoltStore) SaveSnapshot(s Snapshot) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return put(tx.Bucket(snapshotBucket), key(s.Year, s.Version), s)
+	})
+}
+
+// FindSnapshot find the snapshot of the year with the passed version
+func (bs *BoltStore) FindSnapshot(year, version int) (*Snapshot, error) {
+	var s Snapshot
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		return get(tx.Bucket(snapshotBucket), key(year, version), &s)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// FindLatestSnapshot find the last snapshot of the year
+func (bs *BoltStore) FindLatestSnapshot(year int) (*Snapshot, error) {
+	var s Snapshot
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(snapshotBucket).Cursor()
+		// the last key of the year is before the first of the next year
+		k, v := c.Seek(key(year + 1))
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+		if k == nil || !bytes.HasPrefix(k, key(year)) {
+			return ErrNotFound
+		}
+		return json.Unmarshal(v, &s)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// FindSnapshots find the snapshots of the year, without their events
+func (bs *BoltStore) FindSnapshots(year int) ([]Snapshot, error) {
+	snapshots := make([]Snapshot, 0)
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(snapshotBucket).Cursor()
+		prefix := key(year)
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var s Snapshot
+			if err := json.Unmarshal(v, &s); err != nil {
+				return err
+			}
+			s.Events = nil
+			snapshots = append(snapshots, s)
+		}
+		return nil
+	})
+	return snapshots, err
+}
+
+// SaveReport saves the report of an indexing
+func (bs *BoltStore) SaveReport(r Report) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(reportBucket)
+		k := []byte(r.ID)
+
+		var old Report
+		err := get(b, k, &old)
+		if err != nil && err != ErrNotFound {
+			return err
+		}
+		if err == nil {
+			if err := tx.Bucket(reportStartedAtBucket).Delete(reportStartedAtKey(old)); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Bucket(reportStartedAtBucket).Put(reportStartedAtKey(r), k); err != nil {
+			return err
+		}
+		return put(b, k, r)
+	})
+}
+
+func reportStartedAtKey(r Report) []byte {
+	return append(key(r.StartedAt.UnixNano()), r.ID...)
+}
+
+// FindReport find the report of an indexing from its ID
+func (bs *BoltStore) FindReport(ID string) (*Report, error) {
+	var r Report
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		return get(tx.Bucket(reportBucket), []byte(ID), &r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// FindReports find the last reports, the most recent first
+func (bs *BoltStore) FindReports(limit int) ([]Report, error) {
+	reports := make([]Report, 0)
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(reportBucket)
+		c := tx.Bucket(reportStartedAtBucket).Cursor()
+		for k, id := c.Last(); k != nil && (limit <= 0 || len(reports) < limit); k, id = c.Prev() {
+			var r Report
+			if err := get(b, id, &r); err != nil {
+				return err
+			}
+			reports = append(reports, r)
+		}
+		return nil
+	})
+	return reports, err
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestBoltStore(t *testing.T) (*BoltStore, string, func()) {
+	dir, err := ioutil.TempDir("", "boltstore")
+	assert.Nil(t, err)
+	path := filepath.Join(dir, "test.db")
+
+	bs, err := NewBoltStore(path)
+	assert.Nil(t, err)
+
+	return bs, path, func() {
+		bs.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestBoltStore(t *testing.T) {
+	bs, _, cleanup := newTestBoltStore(t)
+	defer cleanup()
+
+	testStoreContract(t, bs)
+}
+
+func TestBoltStoreReopen(t *testing.T) {
+	bs, path, cleanup := newTestBoltStore(t)
+	defer cleanup()
+
+	assert.Nil(t, bs.Save(Speaker{ID: 1, Slug: "mario_rossi", Name: "Mario Rossi", Year: 2018}))
+	assert.Nil(t, bs.SaveConference(Conference{Year: 2017}))
+	assert.Nil(t, bs.SaveConference(Conference{Year: 2018, Title: "FOSDEM 2018"}))
+	assert.Nil(t, bs.Close())
+
+	bs, err := NewBoltStore(path)
+	assert.Nil(t, err)
+	defer bs.Close()
+
+	conf, err := bs.FindLatestConference()
+	assert.Nil(t, err)
+	assert.Equal(t, "FOSDEM 2018", conf.Title)
+
+	s, err := bs.FindByID(1, 2018)
+	assert.Nil(t, err)
+	assert.Equal(t, "Mario Rossi", s.Name)
+}
@@ -0,0 +1,248 @@
+package store
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/enrichman/api-fosdem/names"
+)
+
+// the searches of the stores without a query language share the semantics of the MongoStore
+
+// the limits of the regular expressions searched, that MongoDB runs on a backtracking engine
+const (
+	maxRegexLength      = 64
+	maxRegexRepetitions = 4
+)
+
+// compileSlug returns the case insensitive regular expressions that the slug of the speakers must match
+func compileSlug(f SpeakerFilter) ([]*regexp.Regexp, error) {
+	slugPatterns, err := f.slugPatterns()
+	if err != nil {
+		return nil, err
+	}
+	patterns := make([]*regexp.Regexp, 0)
+	for _, p := range slugPatterns {
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+// matchName returns true if the name contains all the words, once normalized
+func matchName(words []string, name string) bool {
+	normalized := names.Normalize(name)
+	for _, w := range words {
+		if !strings.Contains(normalized, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// sortSpeakers sorts the speakers by ID, and the most recent year first
+func sortSpeakers(speakers []Speaker) {
+	sort.Slice(speakers, func(i, j int) bool {
+		if speakers[i].ID != speakers[j].ID {
+			return speakers[i].ID < speakers[j].ID
+		}
+		return speakers[i].Year > speakers[j].Year
+	})
+}
+
+// sortEvents sorts the events by start, and by ID
+func sortEvents(events []Event) {
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].Start.Equal(events[j].Start) {
+			return events[i].Start.Before(events[j].Start)
+		}
+		return events[i].ID < events[j].ID
+	})
+}
+
+func matchEvent(f EventFilter, e Event) bool {
+	if len(f.IDs) > 0 && !containsInt(f.IDs, e.ID) {
+		return false
+	}
+	if len(f.Years) > 0 && !containsInt(f.Years, e.Year) {
+		return false
+	}
+	if len(f.Days) > 0 && !containsString(f.Days, e.Date) && !containsString(f.Days, strconv.Itoa(e.Day)) {
+		return false
+	}
+	if len(f.Rooms) > 0 && !containsString(f.Rooms, e.Room) {
+		return false
+	}
+	if len(f.Tracks) > 0 && !containsString(f.Tracks, e.Track) {
+		return false
+	}
+	if len(f.Types) > 0 && !containsString(f.Types, e.Type) {
+		return false
+	}
+	if len(f.Languages) > 0 && !containsString(f.Languages, e.Language) {
+		return false
+	}
+	if len(f.PersonIDs) > 0 {
+		found := false
+		for _, p := range e.Persons {
+			found = found || containsInt(f.PersonIDs, p.ID)
+		}
+		if !found {
+			return false
+		}
+	}
+	// the time window matches all the events overlapping it
+	if !f.From.IsZero() && !e.End.After(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !e.Start.Before(f.To) {
+		return false
+	}
+	return true
+}
+
+// page returns the bounds of the page of a list with n elements. A limit of 0 means no limit, as in MongoDB.
+func page(n, offset, limit int) (int, int) {
+	if offset > n {
+		offset = n
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	end := n
+	if limit > 0 && offset+limit < n {
+		end = offset + limit
+	}
+	return offset, end
+}
+
+func matchAll(patterns []*regexp.Regexp, s string) bool {
+	for _, re := range patterns {
+		if !re.MatchString(s) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsInt(values []int, v int) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueInts returns the values without the duplicates, in the same order
+func uniqueInts(values []int) []int {
+	unique := make([]int, 0, len(values))
+	for _, v := range values {
+		if !containsInt(unique, v) {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+// uniqueStrings returns the values without the duplicates, in the same order
+func uniqueStrings(values []string) []string {
+	unique := make([]string, 0, len(values))
+	for _, v := range values {
+		if !containsString(unique, v) {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+// slugPatterns returns the regular expressions that the slug must match, with the user input
+// escaped unless in MatchRegex
+func (f SpeakerFilter) slugPatterns() ([]string, error) {
+	slug := strings.TrimSpace(f.Slug)
+	patterns := make([]string, 0)
+
+	switch f.Match {
+	case "", MatchContains:
+		for _, w := range strings.Fields(slug) {
+			patterns = append(patterns, regexp.QuoteMeta(w))
+		}
+	case MatchExact:
+		if slug != "" {
+			patterns = append(patterns, "^"+regexp.QuoteMeta(slug)+"$")
+		}
+	case MatchPrefix:
+		if slug != "" {
+			patterns = append(patterns, "^"+regexp.QuoteMeta(slug))
+		}
+	case MatchRegex:
+		if len(slug) > maxRegexLength {
+			return nil, InvalidFilterError{"the regex is longer than the limit"}
+		}
+		for _, w := range strings.Fields(slug) {
+			if err := checkRegex(w); err != nil {
+				return nil, err
+			}
+			patterns = append(patterns, w)
+		}
+	default:
+		return nil, InvalidFilterError{"unknown match " + f.Match}
+	}
+	return patterns, nil
+}
+
+// nameWords returns the normalized words that the name of the speaker must contain
+func (f SpeakerFilter) nameWords() []string {
+	return strings.Fields(names.Normalize(f.Name))
+}
+
+// checkRegex rejects the regular expressions that are not valid, or that could take
+// an exponential time: a repetition of repetitions or of alternatives, or too many repetitions
+func checkRegex(pattern string) error {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return InvalidFilterError{err.Error()}
+	}
+
+	repetitions := 0
+	var check func(re *syntax.Regexp, repeated bool) error
+	check = func(re *syntax.Regexp, repeated bool) error {
+		switch re.Op {
+		case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+			if repeated {
+				return InvalidFilterError{"nested repetitions are not allowed in the regex"}
+			}
+			repetitions++
+			if repetitions > maxRegexRepetitions {
+				return InvalidFilterError{"too many repetitions in the regex"}
+			}
+			repeated = true
+		case syntax.OpAlternate:
+			if repeated {
+				return InvalidFilterError{"repeated alternatives are not allowed in the regex"}
+			}
+		}
+		for _, sub := range re.Sub {
+			if err := check(sub, repeated); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return check(re, false)
+}
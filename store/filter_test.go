@@ -0,0 +1,40 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlugPatterns(t *testing.T) {
+	tt := []struct {
+		name     string
+		filter   SpeakerFilter
+		expected []string
+		wantErr  bool
+	}{
+		{name: "empty", filter: SpeakerFilter{Slug: " "}, expected: []string{}},
+		{name: "contains by default", filter: SpeakerFilter{Slug: "mario .*"}, expected: []string{"mario", `\.\*`}},
+		{name: "exact", filter: SpeakerFilter{Slug: "mario_rossi", Match: MatchExact}, expected: []string{"^mario_rossi$"}},
+		{name: "prefix", filter: SpeakerFilter{Slug: "mario(", Match: MatchPrefix}, expected: []string{`^mario\(`}},
+		{name: "regex", filter: SpeakerFilter{Slug: "^mario oy$", Match: MatchRegex}, expected: []string{"^mario", "oy$"}},
+		{name: "unknown match", filter: SpeakerFilter{Slug: "mario", Match: "fuzzy"}, wantErr: true},
+		{name: "invalid regex", filter: SpeakerFilter{Slug: "(", Match: MatchRegex}, wantErr: true},
+		{name: "nested repetitions", filter: SpeakerFilter{Slug: "(a+)+$", Match: MatchRegex}, wantErr: true},
+		{name: "repeated alternatives", filter: SpeakerFilter{Slug: "(a|ab)*c", Match: MatchRegex}, wantErr: true},
+		{name: "too many repetitions", filter: SpeakerFilter{Slug: "a*b*c*d*e*", Match: MatchRegex}, wantErr: true},
+		{name: "too long", filter: SpeakerFilter{Slug: "mario_rossi_mario_rossi_mario_rossi_mario_rossi_mario_rossi_mario_rossi", Match: MatchRegex}, wantErr: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			patterns, err := tc.filter.slugPatterns()
+			if tc.wantErr {
+				assert.IsType(t, InvalidFilterError{}, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, patterns)
+		})
+	}
+}
@@ -0,0 +1,356 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+)
+
+// ErrReadOnly is returned when saving in a read-only store
+var ErrReadOnly = errors.New("read-only store")
+
+type speakerKey struct{ id, year int }
+type dayKey struct{ year, index int }
+type nameKey struct {
+	year int
+	name string
+}
+type eventKey struct{ id, year int }
+type snapshotKey struct{ year, version int }
+
+// MemoryStore keeps everything in memory, searching with the same semantics of the MongoStore.
+// It is safe for concurrent use.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	readOnly bool
+
+	speakers    map[speakerKey]Speaker
+	conferences map[int]Conference
+	days        map[dayKey]Day
+	rooms       map[nameKey]Room
+	tracks      map[nameKey]Track
+	events      map[eventKey]Event
+	snapshots   map[snapshotKey]Snapshot
+	reports     map[string]Report
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// Dataset is the content of a MemoryStore, as loaded from a JSON file
+type Dataset struct {
+	Conferences []Conference
+	Days        []Day
+	Rooms       []Room
+	Tracks      []Track
+	Speakers    []Speaker
+	Events      []Event
+	Snapshots   []Snapshot
+	Reports     []Report
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		speakers:    make(map[speakerKey]Speaker),
+		conferences: make(map[int]Conference),
+		days:        make(map[dayKey]Day),
+		rooms:       make(map[nameKey]Room),
+		tracks:      make(map[nameKey]Track),
+		events:      make(map[eventKey]Event),
+		snapshots:   make(map[snapshotKey]Snapshot),
+		reports:     make(map[string]Report),
+	}
+}
+
+// LoadMemoryStore returns a read-only MemoryStore with the Dataset read from the JSON
+func LoadMemoryStore(r io.Reader) (*MemoryStore, error) {
+	var d Dataset
+	if err := json.NewDecoder(r).Decode(&d); err != nil {
+		return nil, err
+	}
+
+	ms := NewMemoryStore()
+	for _, c := range d.Conferences {
+		ms.conferences[c.Year] = c
+	}
+	for _, day := range d.Days {
+		ms.days[dayKey{day.Year, day.Index}] = day
+	}
+	for _, r := range d.Rooms {
+		ms.rooms[nameKey{r.Year, r.Name}] = r
+	}
+	for _, t := range d.Tracks {
+		ms.tracks[nameKey{t.Year, t.Name}] = t
+	}
+	for _, s := range d.Speakers {
+		ms.speakers[speakerKey{s.ID, s.Year}] = s
+	}
+	for _, e := range d.Events {
+		ms.events[eventKey{e.ID, e.Year}] = e
+	}
+	for _, s := range d.Snapshots {
+		ms.snapshots[snapshotKey{s.Year, s.Version}] = s
+	}
+	for _, r := range d.Reports {
+		ms.reports[r.ID] = r
+	}
+	ms.readOnly = true
+	return ms, nil
+}
+
+// save runs f holding the lock, if the store is not read-only
+func (ms *MemoryStore) save(f func()) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if ms.readOnly {
+		return ErrReadOnly
+	}
+	f()
+	return nil
+}
+
+// Save a speaker of the passed year
+func (ms *MemoryStore) Save(s Speaker) error {
+	return ms.save(func() { ms.speakers[speakerKey{s.ID, s.Year}] = s })
+}
+
+// FindByID find a Speaker from its ID
+func (ms *MemoryStore) FindByID(ID, year int) (*Speaker, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	s, found := ms.speakers[speakerKey{ID, year}]
+	if !found {
+		return nil, ErrNotFound
+	}
+	return &s, nil
+}
+
+// FindSpeakerByProfilePage find a Speaker from its profile page (unique for every year)
+func (ms *MemoryStore) FindSpeakerByProfilePage(profilePage string) (*Speaker, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	for _, s := range ms.speakers {
+		if s.ProfilePage == profilePage {
+			return &s, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Find find a list of Speakers based on the passed params
+func (ms *MemoryStore) Find(f SpeakerFilter) ([]Speaker, int, error) {
+	patterns, err := compileSlug(f)
+	if err != nil {
+		return nil, 0, err
+	}
+	words := f.nameWords()
+
+	ms.mu.RLock()
+	speakersFound := make([]Speaker, 0)
+	for _, s := range ms.speakers {
+		if matchAll(patterns, s.Slug) && matchName(words, s.Name) && (len(f.Years) == 0 || containsInt(f.Years, s.Year)) {
+			speakersFound = append(speakersFound, s)
+		}
+	}
+	ms.mu.RUnlock()
+
+	sortSpeakers(speakersFound)
+
+	start, end := page(len(speakersFound), f.Offset, f.Limit)
+	return speakersFound[start:end], len(speakersFound), nil
+}
+
+// SaveConference saves the main information of the conference of the passed year
+func (ms *MemoryStore) SaveConference(conf Conference) error {
+	return ms.save(func() { ms.conferences[conf.Year] = conf })
+}
+
+// LatestYear returns the year of the last indexed edition of the conference
+func (ms *MemoryStore) LatestYear() (int, error) {
+	conf, err := ms.FindLatestConference()
+	if err != nil {
+		return 0, err
+	}
+	return conf.Year, nil
+}
+
+// FindLatestConference returns the last indexed edition of the conference
+func (ms *MemoryStore) FindLatestConference() (*Conference, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	var latest *Conference
+	for _, c := range ms.conferences {
+		if latest == nil || c.Year > latest.Year {
+			conf := c
+			latest = &conf
+		}
+	}
+	if latest == nil {
+		return nil, ErrNotFound
+	}
+	return latest, nil
+}
+
+// FindConference returns the edition of the conference of the year
+func (ms *MemoryStore) FindConference(year int) (*Conference, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	conf, found := ms.conferences[year]
+	if !found {
+		return nil, ErrNotFound
+	}
+	return &conf, nil
+}
+
+// SaveDay saves a day of the conference
+func (ms *MemoryStore) SaveDay(d Day) error {
+	return ms.save(func() { ms.days[dayKey{d.Year, d.Index}] = d })
+}
+
+// SaveRoom saves a room of the conference
+func (ms *MemoryStore) SaveRoom(r Room) error {
+	return ms.save(func() { ms.rooms[nameKey{r.Year, r.Name}] = r })
+}
+
+// SaveTrack saves a track of the conference
+func (ms *MemoryStore) SaveTrack(t Track) error {
+	return ms.save(func() { ms.tracks[nameKey{t.Year, t.Name}] = t })
+}
+
+// SaveEvent saves an event of the passed year
+func (ms *MemoryStore) SaveEvent(e Event) error {
+	return ms.save(func() { ms.events[eventKey{e.ID, e.Year}] = e })
+}
+
+// FindEventByID find an Event from its ID
+func (ms *MemoryStore) FindEventByID(ID, year int) (*Event, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	e, found := ms.events[eventKey{ID, year}]
+	if !found {
+		return nil, ErrNotFound
+	}
+	return &e, nil
+}
+
+// FindEvents find a list of Events based on the passed filter
+func (ms *MemoryStore) FindEvents(f EventFilter) ([]Event, int, error) {
+	ms.mu.RLock()
+	eventsFound := make([]Event, 0)
+	for _, e := range ms.events {
+		if matchEvent(f, e) {
+			eventsFound = append(eventsFound, e)
+		}
+	}
+	ms.mu.RUnlock()
+
+	sortEvents(eventsFound)
+
+	start, end := page(len(eventsFound), f.Offset, f.Limit)
+	return eventsFound[start:end], len(eventsFound), nil
+}
+
+// DeleteEvents deletes the events of the year, except the ones with the IDs to keep
+func (ms *MemoryStore) DeleteEvents(year int, keepIDs []int) error {
+	keep := make(map[int]bool)
+	for _, ID := range keepIDs {
+		keep[ID] = true
+	}
+	return ms.save(func() {
+		for k := range ms.events {
+			if k.year == year && !keep[k.id] {
+				delete(ms.events, k)
+			}
+		}
+	})
+}
+
+// SaveSnapshot saves a version of the events of the year
+func (ms *MemoryStore) SaveSnapshot(s Snapshot) error {
+	return ms.save(func() { ms.snapshots[snapshotKey{s.Year, s.Version}] = s })
+}
+
+// FindSnapshot find the snapshot of the year with the passed version
+func (ms *MemoryStore) FindSnapshot(year, version int) (*Snapshot, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	s, found := ms.snapshots[snapshotKey{year, version}]
+	if !found {
+		return nil, ErrNotFound
+	}
+	return &s, nil
+}
+
+// FindLatestSnapshot find the last snapshot of the year
+func (ms *MemoryStore) FindLatestSnapshot(year int) (*Snapshot, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	var latest *Snapshot
+	for _, s := range ms.snapshots {
+		if s.Year == year && (latest == nil || s.Version > latest.Version) {
+			snapshot := s
+			latest = &snapshot
+		}
+	}
+	if latest == nil {
+		return nil, ErrNotFound
+	}
+	return latest, nil
+}
+
+// FindSnapshots find the snapshots of the year, without their events
+func (ms *MemoryStore) FindSnapshots(year int) ([]Snapshot, error) {
+	ms.mu.RLock()
+	snapshots := make([]Snapshot, 0)
+	for _, s := range ms.snapshots {
+		if s.Year == year {
+			s.Events = nil
+			snapshots = append(snapshots, s)
+		}
+	}
+	ms.mu.RUnlock()
+
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Version < snapshots[j].Version })
+	return snapshots, nil
+}
+
+// SaveReport saves the report of an indexing
+func (ms *MemoryStore) SaveReport(r Report) error {
+	return ms.save(func() { ms.reports[r.ID] = r })
+}
+
+// FindReport find the report of an indexing from its ID
+func (ms *MemoryStore) FindReport(ID string) (*Report, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	r, found := ms.reports[ID]
+	if !found {
+		return nil, ErrNotFound
+	}
+	return &r, nil
+}
+
+// FindReports find the last reports, the most recent first
+func (ms *MemoryStore) FindReports(limit int) ([]Report, error) {
+	ms.mu.RLock()
+	reports := make([]Report, 0)
+	for _, r := range ms.reports {
+		reports = append(reports, r)
+	}
+	ms.mu.RUnlock()
+
+	sort.Slice(reports, func(i, j int) bool { return reports[i].StartedAt.After(reports[j].StartedAt) })
+
+	_, end := page(len(reports), 0, limit)
+	return reports[:end], nil
+}
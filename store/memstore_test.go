@@ -0,0 +1,32 @@
+package store
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	testStoreContract(t, NewMemoryStore())
+}
+
+func TestLoadMemoryStore(t *testing.T) {
+	dataset := `{
+		"Conferences": [{"Year": 2017}, {"Year": 2018, "Title": "FOSDEM 2018"}],
+		"Speakers": [{"ID": 1, "Slug": "mario_rossi", "Name": "Mario Rossi", "Year": 2018}]
+	}`
+
+	ms, err := LoadMemoryStore(strings.NewReader(dataset))
+	assert.Nil(t, err)
+
+	year, err := ms.LatestYear()
+	assert.Nil(t, err)
+	assert.Equal(t, 2018, year)
+
+	s, err := ms.FindByID(1, 2018)
+	assert.Nil(t, err)
+	assert.Equal(t, "Mario Rossi", s.Name)
+
+	assert.Equal(t, ErrReadOnly, ms.Save(Speaker{ID: 2, Year: 2018}))
+}
@@ -0,0 +1,197 @@
+package store
+
+import (
+	"database/sql"
+
+	"github.com/enrichman/api-fosdem/names"
+)
+
+// migration is a version of the schema of the PostgresStore.
+// The statements are executed before run, that changes the data that cannot be changed with SQL.
+type migration struct {
+	version    int
+	statements []string
+	run        func(tx *sql.Tx) error
+}
+
+// migrations are applied in order, only once: a released migration must never be changed,
+// a new version must be added instead.
+// The documents are saved in the data column, the other columns are used by the searches.
+var migrations = []migration{
+	{
+		version: 1,
+		statements: []string{
+			`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+			`CREATE TABLE speakers (
+				id integer NOT NULL,
+				year integer NOT NULL,
+				slug text NOT NULL,
+				name text NOT NULL,
+				profile_page text NOT NULL,
+				data jsonb NOT NULL,
+				PRIMARY KEY (id, year)
+			)`,
+			`CREATE INDEX speakers_year_idx ON speakers (year)`,
+			`CREATE INDEX speakers_profile_page_idx ON speakers (profile_page)`,
+			`CREATE INDEX speakers_slug_trgm_idx ON speakers USING gin (slug gin_trgm_ops)`,
+			`CREATE INDEX speakers_name_trgm_idx ON speakers USING gin (name gin_trgm_ops)`,
+			`CREATE TABLE conferences (
+				year integer PRIMARY KEY,
+				data jsonb NOT NULL
+			)`,
+			`CREATE TABLE days (
+				year integer NOT NULL,
+				day_index integer NOT NULL,
+				data jsonb NOT NULL,
+				PRIMARY KEY (year, day_index)
+			)`,
+			`CREATE TABLE rooms (
+				year integer NOT NULL,
+				name text NOT NULL,
+				data jsonb NOT NULL,
+				PRIMARY KEY (year, name)
+			)`,
+			`CREATE TABLE tracks (
+				year integer NOT NULL,
+				name text NOT NULL,
+				data jsonb NOT NULL,
+				PRIMARY KEY (year, name)
+			)`,
+			`CREATE TABLE events (
+				id integer NOT NULL,
+				year integer NOT NULL,
+				day integer NOT NULL,
+				date text NOT NULL,
+				room text NOT NULL,
+				track text NOT NULL,
+				type text NOT NULL,
+				language text NOT NULL,
+				start_at timestamptz NOT NULL,
+				end_at timestamptz NOT NULL,
+				person_ids integer[] NOT NULL,
+				data jsonb NOT NULL,
+				PRIMARY KEY (id, year)
+			)`,
+			`CREATE INDEX events_year_idx ON events (year)`,
+			`CREATE INDEX events_room_idx ON events (room)`,
+			`CREATE INDEX events_track_idx ON events (track)`,
+			`CREATE INDEX events_start_at_idx ON events (start_at, id)`,
+			`CREATE INDEX events_person_ids_idx ON events USING gin (person_ids)`,
+			`CREATE TABLE snapshots (
+				year integer NOT NULL,
+				version integer NOT NULL,
+				data jsonb NOT NULL,
+				PRIMARY KEY (year, version)
+			)`,
+			`CREATE TABLE reports (
+				id text PRIMARY KEY,
+				started_at timestamptz NOT NULL,
+				data jsonb NOT NULL
+			)`,
+			`CREATE INDEX reports_started_at_idx ON reports (started_at)`,
+		},
+	},
+	{
+		// the name without accents and punctuation, searched by the name filter.
+		// It is filled for the speakers saved before by the version 3.
+		version: 2,
+		statements: []string{
+			`ALTER TABLE speakers ADD COLUMN search_name text NOT NULL DEFAULT ''`,
+			`CREATE INDEX speakers_search_name_trgm_idx ON speakers USING gin (search_name gin_trgm_ops)`,
+		},
+	},
+	{
+		// the incremental indexing does not save again the unchanged speakers
+		version: 3,
+		run:     backfillSearchNames,
+	},
+}
+
+// backfillSearchNames fills the search_name of the speakers saved without it
+func backfillSearchNames(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT id, year, name FROM speakers WHERE search_name = ''`)
+	if err != nil {
+		return err
+	}
+
+	type speakerName struct {
+		id, year int
+		name     string
+	}
+	speakers := make([]speakerName, 0)
+	for rows.Next() {
+		var s speakerName
+		if err := rows.Scan(&s.id, &s.year, &s.name); err != nil {
+			rows.Close()
+			return err
+		}
+		speakers = append(speakers, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, s := range speakers {
+		_, err := tx.Exec(`UPDATE speakers SET search_name = $1 WHERE id = $2 AND year = $3`, names.Normalize(s.name), s.id, s.year)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrationsLock is the key of the advisory lock that prevents two instances
+// from migrating the same database at startup
+const migrationsLock = 7359248
+
+// migrate applies the missing migrations, each one in its own transaction
+func migrate(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version integer PRIMARY KEY,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if err := applyMigration(db, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrationsLock); err != nil {
+		return err
+	}
+
+	var applied bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, m.version).Scan(&applied)
+	if err != nil || applied {
+		return err
+	}
+
+	for _, s := range m.statements {
+		if _, err := tx.Exec(s); err != nil {
+			return err
+		}
+	}
+	if m.run != nil {
+		if err := m.run(tx); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES ($1)`, m.version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
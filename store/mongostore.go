@@ -1,111 +1,521 @@
 package store
 
 import (
+	"context"
 	"errors"
-	"strings"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
 
+	"github.com/enrichman/api-fosdem/names"
 	mgo "gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
 const (
-	defaultDB         = "api-fosdem"
-	speakerCollection = "speakers"
+	defaultDB            = "api-fosdem"
+	speakerCollection    = "speakers"
+	conferenceCollection = "conferences"
+	dayCollection        = "days"
+	roomCollection       = "rooms"
+	trackCollection      = "tracks"
+	eventCollection      = "events"
+	snapshotCollection   = "snapshots"
+	reportCollection     = "reports"
 )
 
-// Speaker maps the speaker
-type Speaker struct {
-	ID           int
-	Slug         string
-	Name         string
-	ProfileImage string
-	ProfilePage  string
-	Bio          string
-	Year         int
-	Links        []Link
+// DefaultMongoTimeout is the maximum duration of the connection and of every operation of the MongoStore
+const DefaultMongoTimeout = 10 * time.Second
+
+// ErrTimeout is returned when the database does not answer in time
+var ErrTimeout = errors.New("timeout")
+
+// UnavailableError is returned when the database can't be reached, or does not answer in time
+type UnavailableError struct {
+	Err error
+}
+
+func (e UnavailableError) Error() string {
+	return "database unavailable: " + e.Err.Error()
 }
 
-// Link is a detail link owned by a Speaker
-type Link struct {
-	URL   string
-	Title string
+// StatusCode answers 503 to the requests failed because of the database
+func (e UnavailableError) StatusCode() int {
+	return http.StatusServiceUnavailable
+}
+
+// mongoIndexes are created at startup, if missing
+var mongoIndexes = map[string][]mgo.Index{
+	speakerCollection: {
+		{Key: []string{"id", "year"}, Unique: true},
+		{Key: []string{"slug"}},
+		{Key: []string{"searchname"}},
+		{Key: []string{"year"}},
+		{Key: []string{"profilepage"}},
+		{Key: []string{"$text:name", "$text:bio"}, DefaultLanguage: "none"},
+	},
+	conferenceCollection: {{Key: []string{"year"}, Unique: true}},
+	dayCollection:        {{Key: []string{"year", "index"}, Unique: true}},
+	roomCollection:       {{Key: []string{"year", "name"}, Unique: true}},
+	trackCollection:      {{Key: []string{"year", "name"}, Unique: true}},
+	eventCollection: {
+		{Key: []string{"id", "year"}, Unique: true},
+		{Key: []string{"year"}},
+		{Key: []string{"start", "id"}},
+	},
+	snapshotCollection: {{Key: []string{"year", "version"}, Unique: true}},
+	reportCollection: {
+		{Key: []string{"id"}, Unique: true},
+		{Key: []string{"startedat"}},
+	},
 }
 
 // MongoStore can save and retrieve Speakers from MongoDB
 type MongoStore struct {
-	db *mgo.Database
+	session *mgo.Session
+	db      string
+	// Timeout is the maximum duration of every operation
+	Timeout time.Duration
 }
 
-// NewMongoStore creates a new MongoStore
-func NewMongoStore(uri, db string) (*MongoStore, error) {
+var _ Store = (*MongoStore)(nil)
+
+// NewMongoStore creates a new MongoStore, creating the missing indexes.
+// If the timeout is 0 the DefaultMongoTimeout is used.
+func NewMongoStore(uri, db string, timeout time.Duration) (*MongoStore, error) {
 	if db == "" {
 		db = defaultDB
 	}
-	session, err := mgo.Dial(uri)
+	if timeout == 0 {
+		timeout = DefaultMongoTimeout
+	}
+	session, err := mgo.DialWithTimeout(uri, timeout)
 	if err != nil {
+		return nil, UnavailableError{err}
+	}
+
+	ms := &MongoStore{session: session, db: db, Timeout: timeout}
+	if err := ms.ensureIndexes(); err != nil {
+		session.Close()
+		return nil, err
+	}
+	if err := ms.backfillSearchNames(); err != nil {
+		session.Close()
 		return nil, err
 	}
-	return &MongoStore{session.DB(db)}, nil
+	return ms, nil
+}
+
+// Close closes the connections to the database
+func (ms *MongoStore) Close() error {
+	ms.session.Close()
+	return nil
+}
+
+func (ms *MongoStore) ensureIndexes() error {
+	return ms.run(func(db *mgo.Database) error {
+		for collection, indexes := range mongoIndexes {
+			for _, index := range indexes {
+				if err := db.C(collection).EnsureIndex(index); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// searchNameBatch is the number of speakers updated by every operation of the backfill
+const searchNameBatch = 500
+
+// backfillSearchNames sets the searchname of the speakers saved before it was introduced,
+// that the incremental indexing does not save again
+func (ms *MongoStore) backfillSearchNames() error {
+	for {
+		var updated int
+		err := ms.run(func(db *mgo.Database) error {
+			var speakers []struct {
+				ID   int    `bson:"id"`
+				Year int    `bson:"year"`
+				Name string `bson:"name"`
+			}
+			c := db.C(speakerCollection)
+			err := c.Find(bson.M{"searchname": bson.M{"$exists": false}}).
+				Select(bson.M{"id": 1, "year": 1, "name": 1}).
+				Limit(searchNameBatch).
+				All(&speakers)
+			if err != nil {
+				return err
+			}
+			for _, s := range speakers {
+				err := c.Update(bson.M{"id": s.ID, "year": s.Year}, bson.M{"$set": bson.M{"searchname": names.Normalize(s.Name)}})
+				if err != nil && err != mgo.ErrNotFound {
+					return err
+				}
+			}
+			updated = len(speakers)
+			return nil
+		})
+		if err != nil || updated < searchNameBatch {
+			return err
+		}
+	}
+}
+
+// run runs the operation on a copy of the session, so that a broken connection is not reused
+// by the next operations, and stops waiting for it after the timeout
+func (ms *MongoStore) run(op func(db *mgo.Database) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ms.Timeout)
+	defer cancel()
+
+	session := ms.session.Copy()
+	session.SetSyncTimeout(ms.Timeout)
+	session.SetSocketTimeout(ms.Timeout)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- op(session.DB(ms.db))
+	}()
+
+	select {
+	case err := <-done:
+		session.Close()
+		return mongoError(err)
+	case <-ctx.Done():
+		// the session is released when the operation ends, bounded by the socket timeout
+		go func() {
+			<-done
+			session.Close()
+		}()
+		return UnavailableError{ErrTimeout}
+	}
+}
+
+// mongoError converts the errors of mgo to the errors of the store
+func mongoError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if err == mgo.ErrNotFound {
+		return ErrNotFound
+	}
+	if _, ok := err.(net.Error); ok {
+		return UnavailableError{err}
+	}
+	switch err.Error() {
+	case io.EOF.Error(), "no reachable servers", "Closed explicitly":
+		return UnavailableError{err}
+	}
+	return err
 }
 
 // Save a speaker of the passed year
 func (ms *MongoStore) Save(s Speaker) error {
-	c := ms.db.C(speakerCollection)
-	_, err := c.Upsert(
-		bson.M{"id": s.ID, "year": s.Year},
-		bson.M{
-			"$set": bson.M{
-				"id":           s.ID,
-				"slug":         s.Slug,
-				"name":         s.Name,
-				"profileimage": s.ProfileImage,
-				"profilepage":  s.ProfilePage,
-				"bio":          s.Bio,
-				"year":         s.Year,
-				"links":        s.Links,
+	return ms.run(func(db *mgo.Database) error {
+		_, err := db.C(speakerCollection).Upsert(
+			bson.M{"id": s.ID, "year": s.Year},
+			bson.M{
+				"$set": bson.M{
+					"id":               s.ID,
+					"slug":             s.Slug,
+					"name":             s.Name,
+					"searchname":       names.Normalize(s.Name),
+					"profileimage":     s.ProfileImage,
+					"profilepage":      s.ProfilePage,
+					"bio":              s.Bio,
+					"year":             s.Year,
+					"links":            s.Links,
+					"eventslugs":       s.EventSlugs,
+					"pageetag":         s.PageETag,
+					"pagelastmodified": s.PageLastModified,
+					"pagehash":         s.PageHash,
+					"matchmethod":      s.MatchMethod,
+					"matchconfidence":  s.MatchConfidence,
+				},
 			},
-		},
-	)
-	return err
+		)
+		return err
+	})
 }
 
 // FindByID find a Speaker from its ID
 func (ms *MongoStore) FindByID(ID, year int) (*Speaker, error) {
-	c := ms.db.C(speakerCollection)
-	iter := c.Find(bson.M{"id": ID, "year": year}).Iter()
+	var s Speaker
+	err := ms.run(func(db *mgo.Database) error {
+		return db.C(speakerCollection).Find(bson.M{"id": ID, "year": year}).One(&s)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
 
+// FindSpeakerByProfilePage find a Speaker from its profile page (unique for every year)
+func (ms *MongoStore) FindSpeakerByProfilePage(profilePage string) (*Speaker, error) {
 	var s Speaker
-	if iter.Next(&s) {
-		return &s, nil
+	err := ms.run(func(db *mgo.Database) error {
+		return db.C(speakerCollection).Find(bson.M{"profilepage": profilePage}).One(&s)
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nil, errors.New("not found")
+	return &s, nil
 }
 
-// Find find a list of Speakers based on the passed params
-func (ms *MongoStore) Find(limit, offset int, slug string, years []int) ([]Speaker, int, error) {
-	c := ms.db.C(speakerCollection)
+// Find find a list of Speakers based on the passed filter.
+// The name is searched in the name without accents and punctuation saved with the speaker.
+func (ms *MongoStore) Find(f SpeakerFilter) ([]Speaker, int, error) {
+	patterns, err := f.slugPatterns()
+	if err != nil {
+		return nil, 0, err
+	}
 
-	ors := make([]bson.M, 0)
-	for _, n := range strings.Split(slug, " ") {
-		ors = append(ors, bson.M{"slug": bson.RegEx{Pattern: n, Options: "i"}})
+	ands := []bson.M{{}}
+	for _, p := range patterns {
+		ands = append(ands, bson.M{"slug": bson.RegEx{Pattern: p, Options: "i"}})
+	}
+	for _, w := range f.nameWords() {
+		ands = append(ands, bson.M{"searchname": bson.RegEx{Pattern: regexp.QuoteMeta(w)}})
 	}
-	if len(years) > 0 {
-		ors = append(ors, bson.M{"year": bson.M{"$in": years}})
+	if len(f.Years) > 0 {
+		ands = append(ands, bson.M{"year": bson.M{"$in": f.Years}})
 	}
 
-	query := c.Find(bson.M{"$and": ors})
+	speakersFound := make([]Speaker, 0)
+	var count int
+	err = ms.run(func(db *mgo.Database) error {
+		query := db.C(speakerCollection).Find(bson.M{"$and": ands})
 
-	count, err := query.Count()
+		var err error
+		count, err = query.Count()
+		if err != nil {
+			return err
+		}
+		return query.Skip(f.Offset).Limit(f.Limit).Sort("id", "-year").All(&speakersFound)
+	})
 	if err != nil {
 		return nil, 0, err
 	}
 
-	iter := query.Skip(offset).Limit(limit).Sort("id", "-year").Iter()
-	speakersFound := make([]Speaker, 0)
-	var s Speaker
-	for iter.Next(&s) {
-		speakersFound = append(speakersFound, s)
+	return speakersFound, count, nil
+}
+
+// SaveConference saves the main information of the conference of the passed year
+func (ms *MongoStore) SaveConference(conf Conference) error {
+	return ms.upsert(conferenceCollection, bson.M{"year": conf.Year}, conf)
+}
+
+// LatestYear returns the year of the last indexed edition of the conference
+func (ms *MongoStore) LatestYear() (int, error) {
+	conf, err := ms.FindLatestConference()
+	if err != nil {
+		return 0, err
 	}
+	return conf.Year, nil
+}
 
-	return speakersFound, count, nil
+// FindLatestConference returns the last indexed edition of the conference
+func (ms *MongoStore) FindLatestConference() (*Conference, error) {
+	var conf Conference
+	err := ms.run(func(db *mgo.Database) error {
+		return db.C(conferenceCollection).Find(nil).Sort("-year").One(&conf)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+// FindConference returns the edition of the conference of the year
+func (ms *MongoStore) FindConference(year int) (*Conference, error) {
+	var conf Conference
+	err := ms.run(func(db *mgo.Database) error {
+		return db.C(conferenceCollection).Find(bson.M{"year": year}).One(&conf)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+// SaveDay saves a day of the conference
+func (ms *MongoStore) SaveDay(d Day) error {
+	return ms.upsert(dayCollection, bson.M{"year": d.Year, "index": d.Index}, d)
+}
+
+// SaveRoom saves a room of the conference
+func (ms *MongoStore) SaveRoom(r Room) error {
+	return ms.upsert(roomCollection, bson.M{"year": r.Year, "name": r.Name}, r)
+}
+
+// SaveTrack saves a track of the conference
+func (ms *MongoStore) SaveTrack(t Track) error {
+	return ms.upsert(trackCollection, bson.M{"year": t.Year, "name": t.Name}, t)
+}
+
+// upsert sets the fields of the document selected, creating it if missing
+func (ms *MongoStore) upsert(collection string, selector bson.M, doc interface{}) error {
+	return ms.run(func(db *mgo.Database) error {
+		_, err := db.C(collection).Upsert(selector, bson.M{"$set": doc})
+		return err
+	})
+}
+
+// SaveEvent saves an event of the passed year
+func (ms *MongoStore) SaveEvent(e Event) error {
+	return ms.upsert(eventCollection, bson.M{"id": e.ID, "year": e.Year}, e)
+}
+
+// FindEventByID find an Event from its ID
+func (ms *MongoStore) FindEventByID(ID, year int) (*Event, error) {
+	var e Event
+	err := ms.run(func(db *mgo.Database) error {
+		return db.C(eventCollection).Find(bson.M{"id": ID, "year": year}).One(&e)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// FindEvents find a list of Events based on the passed filter
+func (ms *MongoStore) FindEvents(f EventFilter) ([]Event, int, error) {
+	ands := []bson.M{{}}
+	if len(f.IDs) > 0 {
+		ands = append(ands, bson.M{"id": bson.M{"$in": f.IDs}})
+	}
+	if len(f.Years) > 0 {
+		ands = append(ands, bson.M{"year": bson.M{"$in": f.Years}})
+	}
+	if len(f.Days) > 0 {
+		indexes := make([]int, 0)
+		for _, d := range f.Days {
+			if i, err := strconv.Atoi(d); err == nil {
+				indexes = append(indexes, i)
+			}
+		}
+		ands = append(ands, bson.M{"$or": []bson.M{
+			{"day": bson.M{"$in": indexes}},
+			{"date": bson.M{"$in": f.Days}},
+		}})
+	}
+	if len(f.Rooms) > 0 {
+		ands = append(ands, bson.M{"room": bson.M{"$in": f.Rooms}})
+	}
+	if len(f.Tracks) > 0 {
+		ands = append(ands, bson.M{"track": bson.M{"$in": f.Tracks}})
+	}
+	if len(f.Types) > 0 {
+		ands = append(ands, bson.M{"type": bson.M{"$in": f.Types}})
+	}
+	if len(f.Languages) > 0 {
+		ands = append(ands, bson.M{"language": bson.M{"$in": f.Languages}})
+	}
+	if len(f.PersonIDs) > 0 {
+		ands = append(ands, bson.M{"persons.id": bson.M{"$in": f.PersonIDs}})
+	}
+	// the time window matches all the events overlapping it
+	if !f.From.IsZero() {
+		ands = append(ands, bson.M{"end": bson.M{"$gt": f.From}})
+	}
+	if !f.To.IsZero() {
+		ands = append(ands, bson.M{"start": bson.M{"$lt": f.To}})
+	}
+
+	eventsFound := make([]Event, 0)
+	var count int
+	err := ms.run(func(db *mgo.Database) error {
+		query := db.C(eventCollection).Find(bson.M{"$and": ands})
+
+		var err error
+		count, err = query.Count()
+		if err != nil {
+			return err
+		}
+		return query.Skip(f.Offset).Limit(f.Limit).Sort("start", "id").All(&eventsFound)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return eventsFound, count, nil
+}
+
+// DeleteEvents deletes the events of the year, except the ones with the IDs to keep
+func (ms *MongoStore) DeleteEvents(year int, keepIDs []int) error {
+	if keepIDs == nil {
+		keepIDs = []int{}
+	}
+	return ms.run(func(db *mgo.Database) error {
+		_, err := db.C(eventCollection).RemoveAll(bson.M{"year": year, "id": bson.M{"$nin": keepIDs}})
+		return err
+	})
+}
+
+// SaveSnapshot saves a version of the events of the year
+func (ms *MongoStore) SaveSnapshot(s Snapshot) error {
+	return ms.upsert(snapshotCollection, bson.M{"year": s.Year, "version": s.Version}, s)
+}
+
+// FindSnapshot find the snapshot of the year with the passed version
+func (ms *MongoStore) FindSnapshot(year, version int) (*Snapshot, error) {
+	return ms.findSnapshot(func(c *mgo.Collection) *mgo.Query {
+		return c.Find(bson.M{"year": year, "version": version})
+	})
+}
+
+// FindLatestSnapshot find the last snapshot of the year
+func (ms *MongoStore) FindLatestSnapshot(year int) (*Snapshot, error) {
+	return ms.findSnapshot(func(c *mgo.Collection) *mgo.Query {
+		return c.Find(bson.M{"year": year}).Sort("-version")
+	})
+}
+
+func (ms *MongoStore) findSnapshot(query func(c *mgo.Collection) *mgo.Query) (*Snapshot, error) {
+	var s Snapshot
+	err := ms.run(func(db *mgo.Database) error {
+		return query(db.C(snapshotCollection)).One(&s)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// FindSnapshots find the snapshots of the year, without their events
+func (ms *MongoStore) FindSnapshots(year int) ([]Snapshot, error) {
+	snapshots := make([]Snapshot, 0)
+	err := ms.run(func(db *mgo.Database) error {
+		return db.C(snapshotCollection).Find(bson.M{"year": year}).Select(bson.M{"events": 0}).Sort("version").All(&snapshots)
+	})
+	return snapshots, err
+}
+
+// SaveReport saves the report of an indexing
+func (ms *MongoStore) SaveReport(r Report) error {
+	return ms.upsert(reportCollection, bson.M{"id": r.ID}, r)
+}
+
+// FindReport find the report of an indexing from its ID
+func (ms *MongoStore) FindReport(ID string) (*Report, error) {
+	var r Report
+	err := ms.run(func(db *mgo.Database) error {
+		return db.C(reportCollection).Find(bson.M{"id": ID}).One(&r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// FindReports find the last reports, the most recent first
+func (ms *MongoStore) FindReports(limit int) ([]Report, error) {
+	reports := make([]Report, 0)
+	err := ms.run(func(db *mgo.Database) error {
+		return db.C(reportCollection).Find(nil).Sort("-startedat").Limit(limit).All(&reports)
+	})
+	return reports, err
 }
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	mgo "gopkg.in/mgo.v2"
+)
+
+func Test_mongoError(t *testing.T) {
+	netErr := &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}
+	otherErr := errors.New("E11000 duplicate key error")
+
+	tt := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{name: "nil", err: nil, expected: nil},
+		{name: "not found", err: mgo.ErrNotFound, expected: ErrNotFound},
+		{name: "network", err: netErr, expected: UnavailableError{netErr}},
+		{name: "closed connection", err: io.EOF, expected: UnavailableError{io.EOF}},
+		{name: "other", err: otherErr, expected: otherErr},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, mongoError(tc.err))
+		})
+	}
+}
+
+func TestNewMongoStoreUnreachable(t *testing.T) {
+	start := time.Now()
+	_, err := NewMongoStore("127.0.0.1:1", "", 200*time.Millisecond)
+
+	assert.IsType(t, UnavailableError{}, err)
+	assert.Equal(t, http.StatusServiceUnavailable, err.(UnavailableError).StatusCode())
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+func TestMongoStoreContract(t *testing.T) {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI not set")
+	}
+
+	// the contract runs on an empty database
+	ms, err := NewMongoStore(uri, "api-fosdem-test", 0)
+	assert.Nil(t, err)
+	assert.Nil(t, ms.session.DB(ms.db).DropDatabase())
+	ms.Close()
+
+	ms, err = NewMongoStore(uri, "api-fosdem-test", 0)
+	assert.Nil(t, err)
+	defer ms.Close()
+
+	testStoreContract(t, ms)
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/enrichman/api-fosdem/pentabarf"
+)
+
+// ToPentabarf converts the stored Event back to a pentabarf.Event
+func (e Event) ToPentabarf() *pentabarf.Event {
+	event := &pentabarf.Event{
+		ID:          e.ID,
+		Start:       e.Start,
+		StartStr:    e.Start.Format("15:04"),
+		Duration:    e.Duration,
+		DurationStr: formatDuration(e.Duration),
+		Room:        e.Room,
+		Slug:        e.Slug,
+		Title:       e.Title,
+		Subtitle:    e.Subtitle,
+		Track:       e.Track,
+		Type:        e.Type,
+		Language:    e.Language,
+		Abstract:    e.Abstract,
+		Description: e.Description,
+		Persons:     make([]*pentabarf.Person, 0),
+		Links:       make([]*pentabarf.Link, 0),
+	}
+	for _, p := range e.Persons {
+		event.Persons = append(event.Persons, &pentabarf.Person{ID: p.ID, Name: p.Name})
+	}
+	for _, l := range e.Links {
+		event.Links = append(event.Links, &pentabarf.Link{URL: l.URL, Text: l.Title})
+	}
+	return event
+}
+
+// the FOSDEM timeslot, that is not stored
+const timeslotDuration = 5 * time.Minute
+
+// ToPentabarf converts the stored Conference back to a pentabarf.Conference
+func (c Conference) ToPentabarf() *pentabarf.Conference {
+	return &pentabarf.Conference{
+		Title:               c.Title,
+		Subtitle:            c.Subtitle,
+		Venue:               c.Venue,
+		City:                c.City,
+		StartDate:           c.StartDate,
+		StartDateStr:        c.StartDate.Format("2006-01-02"),
+		EndDate:             c.EndDate,
+		EndDateStr:          c.EndDate.Format("2006-01-02"),
+		Days:                c.Days,
+		DayChange:           c.DayChange,
+		DayChangeStr:        formatDurationSeconds(c.DayChange),
+		TimeslotDuration:    timeslotDuration,
+		TimeslotDurationStr: formatDurationSeconds(timeslotDuration),
+	}
+}
+
+// BuildSchedule rebuilds the days and the rooms of a pentabarf.Schedule from the stored events
+func BuildSchedule(events []Event) *pentabarf.Schedule {
+	schedule := &pentabarf.Schedule{Days: make([]*pentabarf.Day, 0)}
+
+	days := make(map[int]*pentabarf.Day)
+	rooms := make(map[int]map[string]*pentabarf.Room)
+	for _, e := range events {
+		d, found := days[e.Day]
+		if !found {
+			d = &pentabarf.Day{Index: e.Day, DateStr: e.Date, Rooms: make([]*pentabarf.Room, 0)}
+			d.Date, _ = time.ParseInLocation("2006-01-02", e.Date, e.Start.Location())
+			days[e.Day] = d
+			rooms[e.Day] = make(map[string]*pentabarf.Room)
+			schedule.Days = append(schedule.Days, d)
+		}
+
+		r, found := rooms[e.Day][e.Room]
+		if !found {
+			r = &pentabarf.Room{Name: e.Room, Events: make([]*pentabarf.Event, 0)}
+			rooms[e.Day][e.Room] = r
+			d.Rooms = append(d.Rooms, r)
+		}
+		r.Events = append(r.Events, e.ToPentabarf())
+	}
+
+	sort.Slice(schedule.Days, func(i, j int) bool {
+		return schedule.Days[i].Index < schedule.Days[j].Index
+	})
+	return schedule
+}
+
+// formatDuration formats the duration as HH:MM
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// formatDurationSeconds formats the duration as HH:MM:SS
+func formatDurationSeconds(d time.Duration) string {
+	return fmt.Sprintf("%s:%02d", formatDuration(d), int(d.Seconds())%60)
+}
@@ -0,0 +1,28 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConferenceToPentabarf(t *testing.T) {
+	c := Conference{
+		Year:      2018,
+		Title:     "FOSDEM 2018",
+		City:      "Brussels",
+		StartDate: time.Date(2018, 2, 3, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2018, 2, 4, 0, 0, 0, 0, time.UTC),
+		Days:      2,
+		DayChange: 9*time.Hour + 31*time.Minute + 10*time.Second,
+	}
+
+	// the offline apps place the events after midnight with the day change
+	conf := c.ToPentabarf()
+	assert.Equal(t, "Brussels", conf.City)
+	assert.Equal(t, c.DayChange, conf.DayChange)
+	assert.Equal(t, "09:31:10", conf.DayChangeStr)
+	assert.Equal(t, "00:05:00", conf.TimeslotDurationStr)
+	assert.Equal(t, "2018-02-03", conf.StartDateStr)
+}
@@ -0,0 +1,438 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/enrichman/api-fosdem/names"
+	"github.com/lib/pq"
+)
+
+// PostgresStore can save and retrieve everything in PostgreSQL
+type PostgresStore struct {
+	db *sql.DB
+}
+
+var _ Store = (*PostgresStore)(nil)
+
+// NewPostgresStore connects to the database and applies the missing migrations of the schema
+func NewPostgresStore(uri string) (*PostgresStore, error) {
+	db, err := sql.Open("postgres", uri)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &PostgresStore{db}, nil
+}
+
+// Close closes the connections to the database
+func (ps *PostgresStore) Close() error {
+	return ps.db.Close()
+}
+
+// conditions builds the WHERE clause of a query, numbering the placeholders of the arguments
+type conditions struct {
+	clauses []string
+	args    []interface{}
+}
+
+// add adds a clause in AND with the others: every %s in the clause is the placeholder of an argument
+func (c *conditions) add(clause string, args ...interface{}) {
+	placeholders := make([]interface{}, len(args))
+	for i, arg := range args {
+		c.args = append(c.args, arg)
+		placeholders[i] = "$" + strconv.Itoa(len(c.args))
+	}
+	c.clauses = append(c.clauses, fmt.Sprintf(clause, placeholders...))
+}
+
+func (c *conditions) where() string {
+	if len(c.clauses) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(c.clauses, " AND ")
+}
+
+// limitOffset returns the LIMIT and OFFSET of a query, a limit of 0 means no limit
+func limitOffset(limit, offset int) string {
+	s := ""
+	if limit > 0 {
+		s += " LIMIT " + strconv.Itoa(limit)
+	}
+	if offset > 0 {
+		s += " OFFSET " + strconv.Itoa(offset)
+	}
+	return s
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+var escapeLike = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace
+
+// speakerConditions matches the slug with the regular expressions of the filter, and the normalized
+// name with its words, so that the search is served by the trigram indexes
+func speakerConditions(f SpeakerFilter) (*conditions, error) {
+	patterns, err := f.slugPatterns()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &conditions{}
+	for _, p := range patterns {
+		c.add("slug ~* %s", p)
+	}
+	for _, w := range f.nameWords() {
+		c.add("search_name LIKE %s", "%"+escapeLike(w)+"%")
+	}
+	if len(f.Years) > 0 {
+		c.add("year = ANY(%s)", pq.Array(f.Years))
+	}
+	return c, nil
+}
+
+func eventConditions(f EventFilter) *conditions {
+	c := &conditions{}
+	if len(f.IDs) > 0 {
+		c.add("id = ANY(%s)", pq.Array(f.IDs))
+	}
+	if len(f.Years) > 0 {
+		c.add("year = ANY(%s)", pq.Array(f.Years))
+	}
+	if len(f.Days) > 0 {
+		indexes := make([]int, 0)
+		for _, d := range f.Days {
+			if i, err := strconv.Atoi(d); err == nil {
+				indexes = append(indexes, i)
+			}
+		}
+		c.add("(day = ANY(%s) OR date = ANY(%s))", pq.Array(indexes), pq.Array(f.Days))
+	}
+	if len(f.Rooms) > 0 {
+		c.add("room = ANY(%s)", pq.Array(f.Rooms))
+	}
+	if len(f.Tracks) > 0 {
+		c.add("track = ANY(%s)", pq.Array(f.Tracks))
+	}
+	if len(f.Types) > 0 {
+		c.add("type = ANY(%s)", pq.Array(f.Types))
+	}
+	if len(f.Languages) > 0 {
+		c.add("language = ANY(%s)", pq.Array(f.Languages))
+	}
+	if len(f.PersonIDs) > 0 {
+		c.add("person_ids && %s", pq.Array(f.PersonIDs))
+	}
+	// the time window matches all the events overlapping it
+	if !f.From.IsZero() {
+		c.add("end_at > %s", f.From)
+	}
+	if !f.To.IsZero() {
+		c.add("start_at < %s", f.To)
+	}
+	return c
+}
+
+// findOne decodes the data of the first row of the query, returning ErrNotFound if missing
+func (ps *PostgresStore) findOne(v interface{}, query string, args ...interface{}) error {
+	var data []byte
+	err := ps.db.QueryRow(query, args...).Scan(&data)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// findAll calls decode with the data of every row of the query
+func (ps *PostgresStore) findAll(decode func(data []byte) error, query string, args ...interface{}) error {
+	rows, err := ps.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return err
+		}
+		if err := decode(data); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// upsert inserts the document, or replaces it if the key already exists
+func (ps *PostgresStore) upsert(query string, v interface{}, args ...interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = ps.db.Exec(query, append(args, data)...)
+	return err
+}
+
+// Save a speaker of the passed year
+func (ps *PostgresStore) Save(s Speaker) error {
+	return ps.upsert(
+		`INSERT INTO speakers (id, year, slug, name, search_name, profile_page, data) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (id, year) DO UPDATE SET
+			slug = EXCLUDED.slug, name = EXCLUDED.name, search_name = EXCLUDED.search_name,
+			profile_page = EXCLUDED.profile_page, data = EXCLUDED.data`,
+		s, s.ID, s.Year, s.Slug, s.Name, names.Normalize(s.Name), s.ProfilePage,
+	)
+}
+
+// FindByID find a Speaker from its ID
+func (ps *PostgresStore) FindByID(ID, year int) (*Speaker, error) {
+	var s Speaker
+	if err := ps.findOne(&s, `SELECT data FROM speakers WHERE id = $1 AND year = $2`, ID, year); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// FindSpeakerByProfilePage find a Speaker from its profile page (unique for every year)
+func (ps *PostgresStore) FindSpeakerByProfilePage(profilePage string) (*Speaker, error) {
+	var s Speaker
+	if err := ps.findOne(&s, `SELECT data FROM speakers WHERE profile_page = $1 LIMIT 1`, profilePage); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Find find a list of Speakers based on the passed filter
+func (ps *PostgresStore) Find(f SpeakerFilter) ([]Speaker, int, error) {
+	c, err := speakerConditions(f)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var count int
+	if err := ps.db.QueryRow(`SELECT count(*) FROM speakers`+c.where(), c.args...).Scan(&count); err != nil {
+		return nil, 0, err
+	}
+
+	speakersFound := make([]Speaker, 0)
+	err = ps.findAll(func(data []byte) error {
+		var s Speaker
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		speakersFound = append(speakersFound, s)
+		return nil
+	}, `SELECT data FROM speakers`+c.where()+` ORDER BY id, year DESC`+limitOffset(f.Limit, f.Offset), c.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return speakersFound, count, nil
+}
+
+// SaveConference saves the main information of the conference of the passed year
+func (ps *PostgresStore) SaveConference(conf Conference) error {
+	return ps.upsert(
+		`INSERT INTO conferences (year, data) VALUES ($1, $2)
+		ON CONFLICT (year) DO UPDATE SET data = EXCLUDED.data`,
+		conf, conf.Year,
+	)
+}
+
+// LatestYear returns the year of the last indexed edition of the conference
+func (ps *PostgresStore) LatestYear() (int, error) {
+	conf, err := ps.FindLatestConference()
+	if err != nil {
+		return 0, err
+	}
+	return conf.Year, nil
+}
+
+// FindLatestConference returns the last indexed edition of the conference
+func (ps *PostgresStore) FindLatestConference() (*Conference, error) {
+	var conf Conference
+	if err := ps.findOne(&conf, `SELECT data FROM conferences ORDER BY year DESC LIMIT 1`); err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+// FindConference returns the edition of the conference of the year
+func (ps *PostgresStore) FindConference(year int) (*Conference, error) {
+	var conf Conference
+	if err := ps.findOne(&conf, `SELECT data FROM conferences WHERE year = $1`, year); err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+// SaveDay saves a day of the conference
+func (ps *PostgresStore) SaveDay(d Day) error {
+	return ps.upsert(
+		`INSERT INTO days (year, day_index, data) VALUES ($1, $2, $3)
+		ON CONFLICT (year, day_index) DO UPDATE SET data = EXCLUDED.data`,
+		d, d.Year, d.Index,
+	)
+}
+
+// SaveRoom saves a room of the conference
+func (ps *PostgresStore) SaveRoom(r Room) error {
+	return ps.upsert(
+		`INSERT INTO rooms (year, name, data) VALUES ($1, $2, $3)
+		ON CONFLICT (year, name) DO UPDATE SET data = EXCLUDED.data`,
+		r, r.Year, r.Name,
+	)
+}
+
+// SaveTrack saves a track of the conference
+func (ps *PostgresStore) SaveTrack(t Track) error {
+	return ps.upsert(
+		`INSERT INTO tracks (year, name, data) VALUES ($1, $2, $3)
+		ON CONFLICT (year, name) DO UPDATE SET data = EXCLUDED.data`,
+		t, t.Year, t.Name,
+	)
+}
+
+// SaveEvent saves an event of the passed year
+func (ps *PostgresStore) SaveEvent(e Event) error {
+	personIDs := make([]int, len(e.Persons))
+	for i, p := range e.Persons {
+		personIDs[i] = p.ID
+	}
+	return ps.upsert(
+		`INSERT INTO events (id, year, day, date, room, track, type, language, start_at, end_at, person_ids, data)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (id, year) DO UPDATE SET
+			day = EXCLUDED.day, date = EXCLUDED.date, room = EXCLUDED.room, track = EXCLUDED.track,
+			type = EXCLUDED.type, language = EXCLUDED.language, start_at = EXCLUDED.start_at,
+			end_at = EXCLUDED.end_at, person_ids = EXCLUDED.person_ids, data = EXCLUDED.data`,
+		e, e.ID, e.Year, e.Day, e.Date, e.Room, e.Track, e.Type, e.Language, e.Start, e.End, pq.Array(personIDs),
+	)
+}
+
+// FindEventByID find an Event from its ID
+func (ps *PostgresStore) FindEventByID(ID, year int) (*Event, error) {
+	var e Event
+	if err := ps.findOne(&e, `SELECT data FROM events WHERE id = $1 AND year = $2`, ID, year); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// FindEvents find a list of Events based on the passed filter
+func (ps *PostgresStore) FindEvents(f EventFilter) ([]Event, int, error) {
+	c := eventConditions(f)
+
+	var count int
+	if err := ps.db.QueryRow(`SELECT count(*) FROM events`+c.where(), c.args...).Scan(&count); err != nil {
+		return nil, 0, err
+	}
+
+	eventsFound := make([]Event, 0)
+	err := ps.findAll(func(data []byte) error {
+		var e Event
+		if err := json.Unmarshal(data, &e); err != nil {
+			return err
+		}
+		eventsFound = append(eventsFound, e)
+		return nil
+	}, `SELECT data FROM events`+c.where()+` ORDER BY start_at, id`+limitOffset(f.Limit, f.Offset), c.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return eventsFound, count, nil
+}
+
+// DeleteEvents deletes the events of the year, except the ones with the IDs to keep
+func (ps *PostgresStore) DeleteEvents(year int, keepIDs []int) error {
+	if keepIDs == nil {
+		keepIDs = []int{}
+	}
+	_, err := ps.db.Exec(`DELETE FROM events WHERE year = $1 AND NOT (id = ANY($2))`, year, pq.Array(keepIDs))
+	return err
+}
+
+// SaveSnapshot saves a version of the events of the year
+func (ps *PostgresStore) SaveSnapshot(s Snapshot) error {
+	return ps.upsert(
+		`INSERT INTO snapshots (year, version, data) VALUES ($1, $2, $3)
+		ON CONFLICT (year, version) DO UPDATE SET data = EXCLUDED.data`,
+		s, s.Year, s.Version,
+	)
+}
+
+// FindSnapshot find the snapshot of the year with the passed version
+func (ps *PostgresStore) FindSnapshot(year, version int) (*Snapshot, error) {
+	var s Snapshot
+	if err := ps.findOne(&s, `SELECT data FROM snapshots WHERE year = $1 AND version = $2`, year, version); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// FindLatestSnapshot find the last snapshot of the year
+func (ps *PostgresStore) FindLatestSnapshot(year int) (*Snapshot, error) {
+	var s Snapshot
+	if err := ps.findOne(&s, `SELECT data FROM snapshots WHERE year = $1 ORDER BY version DESC LIMIT 1`, year); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// FindSnapshots find the snapshots of the year, without their events
+func (ps *PostgresStore) FindSnapshots(year int) ([]Snapshot, error) {
+	snapshots := make([]Snapshot, 0)
+	err := ps.findAll(func(data []byte) error {
+		var s Snapshot
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		snapshots = append(snapshots, s)
+		return nil
+	}, `SELECT data - 'Events' FROM snapshots WHERE year = $1 ORDER BY version`, year)
+	return snapshots, err
+}
+
+// SaveReport saves the report of an indexing
+func (ps *PostgresStore) SaveReport(r Report) error {
+	return ps.upsert(
+		`INSERT INTO reports (id, started_at, data) VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET started_at = EXCLUDED.started_at, data = EXCLUDED.data`,
+		r, r.ID, r.StartedAt,
+	)
+}
+
+// FindReport find the report of an indexing from its ID
+func (ps *PostgresStore) FindReport(ID string) (*Report, error) {
+	var r Report
+	if err := ps.findOne(&r, `SELECT data FROM reports WHERE id = $1`, ID); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// FindReports find the last reports, the most recent first
+func (ps *PostgresStore) FindReports(limit int) ([]Report, error) {
+	reports := make([]Report, 0)
+	err := ps.findAll(func(data []byte) error {
+		var r Report
+		if err := json.Unmarshal(data, &r); err != nil {
+			return err
+		}
+		reports = append(reports, r)
+		return nil
+	}, `SELECT data FROM reports ORDER BY started_at DESC`+limitOffset(limit, 0))
+	return reports, err
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpeakerConditions(t *testing.T) {
+	c, err := speakerConditions(SpeakerFilter{Slug: "mario.rossi", Match: MatchPrefix, Name: "Nicolò", Years: []int{2017, 2018}})
+	assert.Nil(t, err)
+
+	assert.Equal(t, " WHERE slug ~* $1 AND search_name LIKE $2 AND year = ANY($3)", c.where())
+	assert.Equal(t, []interface{}{`^mario\.rossi`, "%nicolo%", pq.Array([]int{2017, 2018})}, c.args)
+
+	c, err = speakerConditions(SpeakerFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, "", c.where())
+
+	_, err = speakerConditions(SpeakerFilter{Slug: "(a+)+", Match: MatchRegex})
+	assert.IsType(t, InvalidFilterError{}, err)
+}
+
+func TestEventConditions(t *testing.T) {
+	from := time.Date(2018, 2, 3, 10, 0, 0, 0, time.UTC)
+	c := eventConditions(EventFilter{
+		Days:      []string{"1", "2018-02-04"},
+		Rooms:     []string{"H.1308"},
+		PersonIDs: []int{1},
+		From:      from,
+	})
+
+	assert.Equal(t, " WHERE (day = ANY($1) OR date = ANY($2)) AND room = ANY($3) AND person_ids && $4 AND end_at > $5", c.where())
+	assert.Equal(t, []interface{}{
+		pq.Array([]int{1}),
+		pq.Array([]string{"1", "2018-02-04"}),
+		pq.Array([]string{"H.1308"}),
+		pq.Array([]int{1}),
+		from,
+	}, c.args)
+}
+
+func TestLimitOffset(t *testing.T) {
+	assert.Equal(t, "", limitOffset(0, 0))
+	assert.Equal(t, " LIMIT 10 OFFSET 20", limitOffset(10, 20))
+	assert.Equal(t, " OFFSET 5", limitOffset(0, 5))
+}
+
+// TestPostgresStore runs against the database of POSTGRES_TEST_URI, that is modified
+func TestPostgresStore(t *testing.T) {
+	uri := os.Getenv("POSTGRES_TEST_URI")
+	if uri == "" {
+		t.Skip("POSTGRES_TEST_URI not set")
+	}
+
+	ps, err := NewPostgresStore(uri)
+	assert.Nil(t, err)
+	defer ps.Close()
+
+	// the migrations already applied are skipped
+	assert.Nil(t, migrate(ps.db))
+
+	assert.Nil(t, ps.Save(Speaker{ID: 1, Slug: "mario_rossi", Name: "Mario Rossi", Year: 2018}))
+	assert.Nil(t, ps.Save(Speaker{ID: 1, Slug: "mario_rossi", Name: "Mario Rossi", Year: 2018, Bio: "updated"}))
+
+	speakers, count, err := ps.Find(SpeakerFilter{Limit: 10, Slug: "ROSSI", Years: []int{2018}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, "updated", speakers[0].Bio)
+
+	// the speakers saved before the search_name are found by name once backfilled
+	_, err = ps.db.Exec(`UPDATE speakers SET search_name = ''`)
+	assert.Nil(t, err)
+	tx, err := ps.db.Begin()
+	assert.Nil(t, err)
+	assert.Nil(t, backfillSearchNames(tx))
+	assert.Nil(t, tx.Commit())
+	_, count, err = ps.Find(SpeakerFilter{Name: "mario"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+
+	start := time.Date(2018, 2, 3, 10, 0, 0, 0, time.UTC)
+	assert.Nil(t, ps.SaveEvent(Event{ID: 10, Year: 2018, Room: "H.1308", Start: start, End: start.Add(time.Hour), Persons: []Person{{ID: 1}}}))
+
+	events, count, err := ps.FindEvents(EventFilter{PersonIDs: []int{1}, To: start.Add(time.Minute)})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, 10, events[0].ID)
+
+	// the events removed from the schedule are deleted
+	assert.Nil(t, ps.SaveEvent(Event{ID: 11, Year: 2018, Start: start, End: start.Add(time.Hour)}))
+	assert.Nil(t, ps.DeleteEvents(2018, []int{11}))
+	_, err = ps.FindEventByID(10, 2018)
+	assert.Equal(t, ErrNotFound, err)
+	_, err = ps.FindEventByID(11, 2018)
+	assert.Nil(t, err)
+
+	_, err = ps.FindByID(1, 2010)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestPostgresStoreContract(t *testing.T) {
+	uri := os.Getenv("POSTGRES_TEST_URI")
+	if uri == "" {
+		t.Skip("POSTGRES_TEST_URI not set")
+	}
+
+	ps, err := NewPostgresStore(uri)
+	assert.Nil(t, err)
+	defer ps.Close()
+
+	// the contract runs on an empty store
+	_, err = ps.db.Exec(`TRUNCATE speakers, conferences, days, rooms, tracks, events, snapshots, reports`)
+	assert.Nil(t, err)
+
+	testStoreContract(t, ps)
+}
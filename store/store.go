@@ -0,0 +1,264 @@
+package store
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Store saves and retrieves everything served by the API and used by the indexer
+type Store interface {
+	Save(s Speaker) error
+	FindByID(ID, year int) (*Speaker, error)
+	FindSpeakerByProfilePage(profilePage string) (*Speaker, error)
+	Find(f SpeakerFilter) ([]Speaker, int, error)
+
+	SaveConference(conf Conference) error
+	LatestYear() (int, error)
+	FindLatestConference() (*Conference, error)
+	FindConference(year int) (*Conference, error)
+	SaveDay(d Day) error
+	SaveRoom(r Room) error
+	SaveTrack(t Track) error
+
+	SaveEvent(e Event) error
+	FindEventByID(ID, year int) (*Event, error)
+	FindEvents(f EventFilter) ([]Event, int, error)
+	DeleteEvents(year int, keepIDs []int) error
+
+	SaveSnapshot(s Snapshot) error
+	FindSnapshot(year, version int) (*Snapshot, error)
+	FindLatestSnapshot(year int) (*Snapshot, error)
+	FindSnapshots(year int) ([]Snapshot, error)
+
+	SaveReport(r Report) error
+	FindReport(ID string) (*Report, error)
+	FindReports(limit int) ([]Report, error)
+}
+
+// ErrNotFound is returned when the searched document does not exist
+var ErrNotFound = errors.New("not found")
+
+// InvalidFilterError is returned when the parameters of a search are not valid
+type InvalidFilterError struct {
+	Reason string
+}
+
+func (e InvalidFilterError) Error() string {
+	return "invalid filter: " + e.Reason
+}
+
+// StatusCode answers 400 to the requests with an invalid filter
+func (e InvalidFilterError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// Speaker maps the speaker
+type Speaker struct {
+	ID           int
+	Slug         string
+	Name         string
+	ProfileImage string
+	ProfilePage  string
+	Bio          string
+	Year         int
+	Links        []Link
+	// the slugs of the events listed in the profile page, to match again the unchanged speakers
+	EventSlugs []string
+	// the version of the profile page, used by the incremental indexing
+	PageETag         string
+	PageLastModified string
+	PageHash         string
+	// how the speaker was matched to the person of the schedule, and the confidence from 0 to 1
+	MatchMethod     string
+	MatchConfidence float64
+}
+
+// Link is a detail link owned by a Speaker
+type Link struct {
+	URL   string
+	Title string
+}
+
+// Conference maps the main information about an edition of the conference
+type Conference struct {
+	Year      int
+	Title     string
+	Subtitle  string
+	Venue     string
+	City      string
+	StartDate time.Time
+	EndDate   time.Time
+	Days      int
+	// DayChange is the time the days start, the events before it are of the previous day
+	DayChange time.Duration
+	// ScheduleHash is the hash of the indexed schedule, UpdatedAt the time it last changed
+	ScheduleHash string
+	UpdatedAt    time.Time
+}
+
+// Day is a day of the conference
+type Day struct {
+	Year  int
+	Index int
+	Date  string
+}
+
+// Room is a room used during an edition of the conference
+type Room struct {
+	Year int
+	Name string
+}
+
+// Track is a track of an edition of the conference
+type Track struct {
+	Year int
+	Name string
+}
+
+// Event maps the event
+type Event struct {
+	ID          int
+	Year        int
+	Slug        string
+	Title       string
+	Subtitle    string
+	Track       string
+	Type        string
+	Language    string
+	Room        string
+	Day         int
+	Date        string
+	Start       time.Time
+	End         time.Time
+	Duration    time.Duration
+	Abstract    string
+	Description string
+	Persons     []Person
+	Links       []Link
+}
+
+// Person is a speaker of an Event
+type Person struct {
+	ID   int
+	Name string
+}
+
+// Snapshot is a version of the events of a year, saved every time the schedule changes
+type Snapshot struct {
+	Year      int
+	Version   int
+	CreatedAt time.Time
+	Events    []Event
+}
+
+// Report is the outcome of an indexing
+type Report struct {
+	ID             string
+	Status         string
+	StartedAt      time.Time
+	EndedAt        *time.Time
+	Saved          int
+	Skipped        int
+	Unchanged      int
+	Failed         int
+	Years          []YearReport
+	Errors         []string
+	Attempts       []Attempt
+	BreakerChanges []BreakerChange
+}
+
+// YearReport is the outcome of the indexing of a year
+type YearReport struct {
+	Year              int
+	Status            string
+	Events            int
+	Speakers          int
+	Saved             int
+	Skipped           int
+	Unchanged         int
+	Failed            int
+	Error             string
+	UnmatchedSpeakers []string
+	UncertainMatches  []SpeakerMatch
+	Conflicts         []SpeakerConflict
+	PageFailures      []PageFailure
+	StoreErrors       []string
+}
+
+// PageFailure is a page that could not be fetched or parsed
+type PageFailure struct {
+	URL   string
+	Error string
+}
+
+// SpeakerMatch is a speaker matched to a person of the schedule
+type SpeakerMatch struct {
+	Name       string
+	PersonID   int
+	PersonName string
+	Method     string
+	Confidence float64
+}
+
+// SpeakerConflict is a speaker not saved, as its person was matched to another speaker
+type SpeakerConflict struct {
+	Name      string
+	PersonID  int
+	MatchedTo string
+}
+
+// Attempt is a retried request to the FOSDEM website
+type Attempt struct {
+	URL    string
+	Number int
+	Error  string
+	Wait   string
+	At     time.Time
+}
+
+// BreakerChange is a change of the state of the circuit breaker of the requests to the FOSDEM website
+type BreakerChange struct {
+	From string
+	To   string
+	At   time.Time
+}
+
+// EventFilter contains the parameters used to search through the events.
+// Empty fields are ignored, multiple values of the same field are in OR.
+type EventFilter struct {
+	Limit     int
+	Offset    int
+	IDs       []int
+	Years     []int
+	Days      []string // index or date of the day
+	Rooms     []string
+	Tracks    []string
+	Types     []string
+	Languages []string
+	PersonIDs []int
+	From      time.Time
+	To        time.Time
+}
+
+// the modes used to match the slug of the speakers
+const (
+	MatchExact    = "exact"
+	MatchPrefix   = "prefix"
+	MatchContains = "contains"
+	MatchRegex    = "regex"
+)
+
+// SpeakerFilter contains the parameters used to search through the speakers.
+// Empty fields are ignored, multiple years are in OR.
+type SpeakerFilter struct {
+	Limit  int
+	Offset int
+	// Slug is matched as a whole with MatchExact and MatchPrefix, while with MatchContains
+	// (the default) and MatchRegex the slug must match all its words
+	Slug  string
+	Match string
+	// Name must be contained in the name of the speaker, ignoring the case, the accents and the punctuation
+	Name  string
+	Years []int
+}
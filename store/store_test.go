@@ -0,0 +1,223 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testStoreContract checks the semantics shared by all the stores, on an empty store
+func testStoreContract(t *testing.T, s Store) {
+	for _, sp := range []Speaker{
+		{ID: 1, Slug: "mario_rossi", Name: "Mario Rossi", Year: 2017, ProfilePage: "https://fosdem.org/2017/schedule/speaker/mario_rossi/"},
+		{ID: 1, Slug: "mario_rossi", Name: "Mario Rossi", Year: 2018, ProfilePage: "https://fosdem.org/2018/schedule/speaker/mario_rossi/", EventSlugs: []string{"event_123_slug", "event_234_slug"}},
+		{ID: 2, Slug: "paolo_bianchi", Name: "Paolo Bianchi", Year: 2018, ProfilePage: "https://fosdem.org/2018/schedule/speaker/paolo_bianchi/"},
+		{ID: 3, Slug: "mario_bianchi", Name: "Mario Nicolò Bianchi", Year: 2018, ProfilePage: "https://fosdem.org/2018/schedule/speaker/mario_bianchi/"},
+	} {
+		assert.Nil(t, s.Save(sp))
+	}
+
+	start := time.Date(2018, 2, 3, 10, 0, 0, 0, time.UTC)
+	for _, e := range []Event{
+		{ID: 20, Year: 2018, Day: 1, Date: "2018-02-03", Room: "K.1.105", Track: "Go", Start: start, End: start.Add(time.Hour), Persons: []Person{{ID: 1}}},
+		{ID: 10, Year: 2018, Day: 1, Date: "2018-02-03", Room: "H.1308", Track: "Go", Start: start, End: start.Add(30 * time.Minute), Persons: []Person{{ID: 2}}},
+		{ID: 30, Year: 2018, Day: 2, Date: "2018-02-04", Room: "H.1308", Track: "Rust", Start: start.Add(24 * time.Hour), End: start.Add(25 * time.Hour)},
+		{ID: 40, Year: 2017, Day: 1, Date: "2017-02-04", Room: "H.1308", Track: "Go", Start: start.AddDate(-1, 0, 0), End: start.AddDate(-1, 0, 0).Add(time.Hour)},
+	} {
+		assert.Nil(t, s.SaveEvent(e))
+	}
+
+	t.Run("speakers", func(t *testing.T) { testStoreSpeakers(t, s) })
+	t.Run("events", func(t *testing.T) { testStoreEvents(t, s) })
+	t.Run("snapshots and reports", func(t *testing.T) { testStoreSnapshotsAndReports(t, s) })
+	t.Run("conferences", func(t *testing.T) { testStoreConferences(t, s) })
+}
+
+func testStoreSpeakers(t *testing.T, s Store) {
+	tt := []struct {
+		name          string
+		filter        SpeakerFilter
+		expected      []speakerKey
+		expectedCount int
+	}{
+		{name: "all", expected: []speakerKey{{1, 2018}, {1, 2017}, {2, 2018}, {3, 2018}}, expectedCount: 4},
+		{name: "year", filter: SpeakerFilter{Years: []int{2017}}, expected: []speakerKey{{1, 2017}}, expectedCount: 1},
+		{name: "repeated years", filter: SpeakerFilter{Slug: "bianchi", Years: []int{2018, 2018}}, expected: []speakerKey{{2, 2018}, {3, 2018}}, expectedCount: 2},
+		{name: "all the words", filter: SpeakerFilter{Slug: "MARIO bianchi"}, expected: []speakerKey{{3, 2018}}, expectedCount: 1},
+		{name: "escaped", filter: SpeakerFilter{Slug: "^paolo"}, expected: []speakerKey{}, expectedCount: 0},
+		{name: "exact", filter: SpeakerFilter{Slug: "mario_rossi", Match: MatchExact, Years: []int{2018}}, expected: []speakerKey{{1, 2018}}, expectedCount: 1},
+		{name: "exact ignoring the case", filter: SpeakerFilter{Slug: "MARIO_ROSSI", Match: MatchExact}, expected: []speakerKey{{1, 2018}, {1, 2017}}, expectedCount: 2},
+		{name: "exact not a prefix", filter: SpeakerFilter{Slug: "mario", Match: MatchExact}, expected: []speakerKey{}, expectedCount: 0},
+		{name: "prefix", filter: SpeakerFilter{Slug: "mario", Match: MatchPrefix}, expected: []speakerKey{{1, 2018}, {1, 2017}, {3, 2018}}, expectedCount: 3},
+		{name: "prefix of the year", filter: SpeakerFilter{Slug: "Mario", Match: MatchPrefix, Years: []int{2018}}, expected: []speakerKey{{1, 2018}, {3, 2018}}, expectedCount: 2},
+		{name: "regular expression", filter: SpeakerFilter{Slug: "^paolo", Match: MatchRegex}, expected: []speakerKey{{2, 2018}}, expectedCount: 1},
+		{name: "name without accents", filter: SpeakerFilter{Name: "NICOLO", Years: []int{2018}}, expected: []speakerKey{{3, 2018}}, expectedCount: 1},
+		{name: "page", filter: SpeakerFilter{Limit: 2, Offset: 1}, expected: []speakerKey{{1, 2017}, {2, 2018}}, expectedCount: 4},
+		{name: "offset over", filter: SpeakerFilter{Limit: 2, Offset: 10}, expected: []speakerKey{}, expectedCount: 4},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assertSpeakers(t, s, tc.filter, tc.expected, tc.expectedCount)
+		})
+	}
+
+	_, _, err := s.Find(SpeakerFilter{Slug: "(", Match: MatchRegex})
+	assert.IsType(t, InvalidFilterError{}, err)
+
+	// the events of the speaker are saved, to match it again when its page is unchanged
+	speakers, _, err := s.Find(SpeakerFilter{Slug: "mario_rossi", Match: MatchExact, Years: []int{2018}})
+	assert.Nil(t, err)
+	if assert.Len(t, speakers, 1) {
+		assert.Equal(t, []string{"event_123_slug", "event_234_slug"}, speakers[0].EventSlugs)
+	}
+
+	sp, err := s.FindSpeakerByProfilePage("https://fosdem.org/2017/schedule/speaker/mario_rossi/")
+	assert.Nil(t, err)
+	assert.Equal(t, 2017, sp.Year)
+	_, err = s.FindSpeakerByProfilePage("https://fosdem.org/2017/schedule/speaker/mario/")
+	assert.Equal(t, ErrNotFound, err)
+	_, err = s.FindByID(2, 2017)
+	assert.Equal(t, ErrNotFound, err)
+
+	// a speaker saved again with a new slug is found only by the new one
+	assert.Nil(t, s.Save(Speaker{ID: 2, Slug: "paolo_verdi", Name: "Paolo Verdi", Year: 2018, ProfilePage: "https://fosdem.org/2018/schedule/speaker/paolo_verdi/"}))
+	assertSpeakers(t, s, SpeakerFilter{Slug: "paolo_bianchi"}, []speakerKey{}, 0)
+	assertSpeakers(t, s, SpeakerFilter{Slug: "paolo_verdi", Match: MatchExact}, []speakerKey{{2, 2018}}, 1)
+	assertSpeakers(t, s, SpeakerFilter{Name: "verdi"}, []speakerKey{{2, 2018}}, 1)
+}
+
+func assertSpeakers(t *testing.T, s Store, f SpeakerFilter, expected []speakerKey, expectedCount int) {
+	speakers, count, err := s.Find(f)
+	assert.Nil(t, err)
+	assert.Equal(t, expectedCount, count)
+
+	keys := make([]speakerKey, 0)
+	for _, sp := range speakers {
+		keys = append(keys, speakerKey{sp.ID, sp.Year})
+	}
+	assert.Equal(t, expected, keys)
+}
+
+func testStoreEvents(t *testing.T, s Store) {
+	start := time.Date(2018, 2, 3, 10, 0, 0, 0, time.UTC)
+
+	tt := []struct {
+		name     string
+		filter   EventFilter
+		expected []int
+	}{
+		{name: "all, by start and id", filter: EventFilter{}, expected: []int{40, 10, 20, 30}},
+		{name: "year", filter: EventFilter{Years: []int{2018}}, expected: []int{10, 20, 30}},
+		{name: "repeated years", filter: EventFilter{Years: []int{2018, 2018}}, expected: []int{10, 20, 30}},
+		{name: "day index", filter: EventFilter{Days: []string{"2"}}, expected: []int{30}},
+		{name: "day date", filter: EventFilter{Days: []string{"2018-02-03"}}, expected: []int{10, 20}},
+		{name: "room", filter: EventFilter{Rooms: []string{"H.1308"}, Years: []int{2018}}, expected: []int{10, 30}},
+		{name: "repeated rooms", filter: EventFilter{Rooms: []string{"H.1308", "H.1308"}, Years: []int{2018}}, expected: []int{10, 30}},
+		{name: "room and track", filter: EventFilter{Rooms: []string{"H.1308"}, Tracks: []string{"Go"}}, expected: []int{40, 10}},
+		{name: "track", filter: EventFilter{Tracks: []string{"Rust", "Go"}}, expected: []int{40, 10, 20, 30}},
+		{name: "repeated tracks", filter: EventFilter{Tracks: []string{"Go", "Go"}}, expected: []int{40, 10, 20}},
+		{name: "person", filter: EventFilter{PersonIDs: []int{1, 5}}, expected: []int{20}},
+		{name: "overlapping window", filter: EventFilter{From: start.Add(45 * time.Minute), To: start.Add(25 * time.Hour)}, expected: []int{20, 30}},
+		{name: "page", filter: EventFilter{Limit: 1, Offset: 1}, expected: []int{10}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assertEvents(t, s, tc.filter, tc.expected)
+		})
+	}
+
+	// the event moved to another room is not found in the old one anymore
+	e, err := s.FindEventByID(30, 2018)
+	assert.Nil(t, err)
+	e.Room = "K.1.105"
+	assert.Nil(t, s.SaveEvent(*e))
+	assertEvents(t, s, EventFilter{Rooms: []string{"H.1308"}, Years: []int{2018}}, []int{10})
+
+	// the deleted events are not found anymore, and the ones of the other years are kept
+	assert.Nil(t, s.DeleteEvents(2018, []int{20}))
+	_, err = s.FindEventByID(10, 2018)
+	assert.Equal(t, ErrNotFound, err)
+	assertEvents(t, s, EventFilter{}, []int{40, 20})
+	assertEvents(t, s, EventFilter{Rooms: []string{"H.1308"}}, []int{40})
+	assertEvents(t, s, EventFilter{Tracks: []string{"Go", "Rust"}}, []int{40, 20})
+
+	assert.Nil(t, s.DeleteEvents(2018, nil))
+	assertEvents(t, s, EventFilter{}, []int{40})
+}
+
+func assertEvents(t *testing.T, s Store, f EventFilter, expected []int) {
+	events, _, err := s.FindEvents(f)
+	assert.Nil(t, err)
+
+	ids := make([]int, 0)
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+	assert.Equal(t, expected, ids)
+}
+
+func testStoreSnapshotsAndReports(t *testing.T, s Store) {
+	now := time.Now()
+
+	assert.Nil(t, s.SaveSnapshot(Snapshot{Year: 2018, Version: 2, Events: []Event{{ID: 1}}}))
+	assert.Nil(t, s.SaveSnapshot(Snapshot{Year: 2018, Version: 1, Events: []Event{{ID: 1}}}))
+	assert.Nil(t, s.SaveSnapshot(Snapshot{Year: 2019, Version: 7}))
+
+	latest, err := s.FindLatestSnapshot(2018)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, latest.Version)
+	assert.Len(t, latest.Events, 1)
+
+	_, err = s.FindLatestSnapshot(2017)
+	assert.Equal(t, ErrNotFound, err)
+
+	snapshots, err := s.FindSnapshots(2018)
+	assert.Nil(t, err)
+	assert.Equal(t, []Snapshot{{Year: 2018, Version: 1}, {Year: 2018, Version: 2}}, snapshots)
+
+	_, err = s.FindSnapshot(2017, 1)
+	assert.Equal(t, ErrNotFound, err)
+
+	assert.Nil(t, s.SaveReport(Report{ID: "old", StartedAt: now.Add(-time.Hour)}))
+	assert.Nil(t, s.SaveReport(Report{ID: "new", StartedAt: now}))
+	// saved again when finished, it must not be listed twice
+	assert.Nil(t, s.SaveReport(Report{ID: "new", StartedAt: now, Status: "completed"}))
+
+	reports, err := s.FindReports(0)
+	assert.Nil(t, err)
+	assert.Len(t, reports, 2)
+	assert.Equal(t, "new", reports[0].ID)
+	assert.Equal(t, "completed", reports[0].Status)
+
+	reports, err = s.FindReports(1)
+	assert.Nil(t, err)
+	assert.Len(t, reports, 1)
+
+	_, err = s.FindReport("missing")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func testStoreConferences(t *testing.T, s Store) {
+	_, err := s.FindLatestConference()
+	assert.Equal(t, ErrNotFound, err)
+
+	assert.Nil(t, s.SaveConference(Conference{Year: 2017}))
+	updatedAt := time.Date(2018, 1, 20, 10, 0, 0, 0, time.UTC)
+	assert.Nil(t, s.SaveConference(Conference{Year: 2018, Title: "FOSDEM 2018", ScheduleHash: "abc", UpdatedAt: updatedAt}))
+
+	conf, err := s.FindLatestConference()
+	assert.Nil(t, err)
+	assert.Equal(t, "FOSDEM 2018", conf.Title)
+	assert.Equal(t, "abc", conf.ScheduleHash)
+	assert.True(t, updatedAt.Equal(conf.UpdatedAt))
+
+	year, err := s.LatestYear()
+	assert.Nil(t, err)
+	assert.Equal(t, 2018, year)
+
+	_, err = s.FindConference(2016)
+	assert.Equal(t, ErrNotFound, err)
+}
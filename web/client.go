@@ -0,0 +1,202 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/enrichman/api-fosdem/retry"
+)
+
+const (
+	defaultWorkers           = 4
+	defaultRequestsPerSecond = 5
+	defaultUserAgent         = "api-fosdem (+https://github.com/enrichman/api-fosdem)"
+
+	// maxRetryAfter is the number of times a request is sent again when the server answers with a Retry-After,
+	// if there is no Retrier
+	maxRetryAfter = 3
+	// maxRetryAfterWait is the longest Retry-After honoured, to avoid blocking the indexing
+	maxRetryAfterWait = 5 * time.Minute
+)
+
+// Options configures how the profile pages are scraped
+type Options struct {
+	// Workers is the number of profile pages fetched in parallel
+	Workers int
+	// RequestsPerSecond is the global limit of the requests sent to the website, 0 means no limit
+	RequestsPerSecond float64
+	// UserAgent is the User-Agent header sent with every request
+	UserAgent string
+	// Retrier retries the requests failed with a timeout, a refused or reset connection or a 5xx, if set
+	Retrier *retry.Retrier
+}
+
+// DefaultOptions returns the Options used if not configured
+func DefaultOptions() Options {
+	return Options{
+		Workers:           defaultWorkers,
+		RequestsPerSecond: defaultRequestsPerSecond,
+		UserAgent:         defaultUserAgent,
+	}
+}
+
+// client sends the requests to the website, limiting their rate and honouring the Retry-After
+type client struct {
+	httpClient *http.Client
+	userAgent  string
+	limiter    *rateLimiter
+	retrier    *retry.Retrier
+}
+
+func newClient(opts Options) *client {
+	userAgent := opts.UserAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+	return &client{
+		httpClient: http.DefaultClient,
+		userAgent:  userAgent,
+		limiter:    newRateLimiter(opts.RequestsPerSecond),
+		retrier:    opts.Retrier,
+	}
+}
+
+// do sends the request, retrying it with the Retrier if set, that waits the Retry-After of the response.
+// A 5xx response is returned as a *retry.StatusError.
+// Without a Retrier only the responses with a Retry-After are sent again.
+// The waits are interrupted when the context of the request is done.
+func (c *client) do(req *http.Request) (*http.Response, error) {
+	if c.retrier == nil {
+		for i := 0; ; i++ {
+			resp, err := c.send(req)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := retryAfter(resp); !ok || i == maxRetryAfter {
+				return resp, nil
+			}
+			resp.Body.Close()
+		}
+	}
+
+	var resp *http.Response
+	err := c.retrier.Do(req.Context(), req.URL.String(), func() error {
+		var err error
+		resp, err = c.send(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+			resp.Body.Close()
+			wait, _ := retryAfter(resp)
+			return &retry.StatusError{StatusCode: resp.StatusCode, RetryAfter: wait}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// send sends the request once the rate limiter allows it. A Retry-After of the response
+// delays the next requests of all the workers, not only the one that got the response.
+func (c *client) send(req *http.Request) (*http.Response, error) {
+	req.Header.Set("User-Agent", c.userAgent)
+
+	if err := c.limiter.wait(req.Context()); err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if wait, ok := retryAfter(resp); ok {
+		c.limiter.delay(wait)
+	}
+	return resp, nil
+}
+
+// retryAfter returns the wait asked by a 429 or 503 response
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+	return parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+}
+
+// parseRetryAfter parses the Retry-After header, both in seconds and as an HTTP date
+func parseRetryAfter(header string, now time.Time) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(header); err == nil {
+		wait = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(header); err == nil {
+		wait = date.Sub(now)
+	} else {
+		return 0, false
+	}
+
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > maxRetryAfterWait {
+		wait = maxRetryAfterWait
+	}
+	return wait, true
+}
+
+// rateLimiter spaces the requests sent by all the workers
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(requestsPerSecond float64) *rateLimiter {
+	var interval time.Duration
+	if requestsPerSecond > 0 {
+		interval = time.Duration(float64(time.Second) / requestsPerSecond)
+	}
+	return &rateLimiter{interval: interval}
+}
+
+// wait blocks until the next request can be sent, or the context is done
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	sleep := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if sleep <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(sleep)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// delay postpones all the next requests of at least d
+func (l *rateLimiter) delay(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); l.next.Before(until) {
+		l.next = until
+	}
+}
@@ -0,0 +1,121 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/enrichman/api-fosdem/retry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2018, 2, 3, 10, 0, 0, 0, time.UTC)
+
+	tt := []struct {
+		name     string
+		header   string
+		expected time.Duration
+		ok       bool
+	}{
+		{name: "empty", header: "", ok: false},
+		{name: "seconds", header: "120", expected: 2 * time.Minute, ok: true},
+		{name: "date", header: "Sat, 03 Feb 2018 10:00:30 GMT", expected: 30 * time.Second, ok: true},
+		{name: "past date", header: "Sat, 03 Feb 2018 09:00:00 GMT", expected: 0, ok: true},
+		{name: "too long", header: "86400", expected: maxRetryAfterWait, ok: true},
+		{name: "invalid", header: "soon", ok: false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			wait, ok := parseRetryAfter(tc.header, now)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expected, wait)
+		})
+	}
+}
+
+func TestClientRetryAfter(t *testing.T) {
+	var hits int
+	var userAgents []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		userAgents = append(userAgents, r.UserAgent())
+		if hits == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	c := newClient(Options{UserAgent: "test-agent"})
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := c.do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"test-agent", "test-agent"}, userAgents)
+}
+
+func TestClientRetryAfterAttempts(t *testing.T) {
+	tt := []struct {
+		name         string
+		retrier      *retry.Retrier
+		expectedErr  error
+		expectedHits int32
+		expectedCode int
+	}{
+		{
+			name:         "without retrier",
+			expectedHits: maxRetryAfter + 1,
+			expectedCode: http.StatusTooManyRequests,
+		},
+		{
+			name:         "with retrier",
+			retrier:      retry.New(retry.Policy{MaxAttempts: 3}, nil),
+			expectedErr:  &retry.StatusError{StatusCode: http.StatusTooManyRequests},
+			expectedHits: 3,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			// the server keeps answering with a Retry-After
+			var hits int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&hits, 1)
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+			}))
+			defer srv.Close()
+
+			c := newClient(Options{Retrier: tc.retrier})
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			resp, err := c.do(req)
+			assert.Equal(t, tc.expectedErr, err)
+			if resp != nil {
+				resp.Body.Close()
+				assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			}
+
+			// the request is sent again by only one layer
+			assert.Equal(t, tc.expectedHits, atomic.LoadInt32(&hits))
+		})
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(20)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, l.wait(context.Background()))
+	}
+	assert.True(t, time.Since(start) >= 100*time.Millisecond)
+}
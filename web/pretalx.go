@@ -0,0 +1,235 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/enrichman/api-fosdem/names"
+)
+
+const (
+	defaultPretalxEvent = "fosdem-%d"
+	// pretalxPageSize is the number of speakers requested for every page of the API
+	pretalxPageSize = 100
+)
+
+// PretalxOptions configures the pretalx API the speakers are read from
+type PretalxOptions struct {
+	// BaseURL is the URL of the pretalx instance, i.e. https://pretalx.fosdem.org
+	BaseURL string
+	// Token is the API token sent in the Authorization header, if set
+	Token string
+	// Event is the slug of the event of the year, with %d replaced by the year (default fosdem-%d)
+	Event string
+}
+
+// pretalxPage is a page of the speakers of the pretalx API
+type pretalxPage struct {
+	Count   int              `json:"count"`
+	Next    string           `json:"next"`
+	Results []pretalxSpeaker `json:"results"`
+}
+
+type pretalxSpeaker struct {
+	Code        string   `json:"code"`
+	Name        string   `json:"name"`
+	Biography   string   `json:"biography"`
+	Avatar      string   `json:"avatar"`
+	AvatarURL   string   `json:"avatar_url"`
+	Submissions []string `json:"submissions"`
+}
+
+// PretalxSpeakerService reads the speakers from the API of pretalx, instead of scraping the website.
+// It has the same contract of the SpeakerService.
+type PretalxSpeakerService struct {
+	c       *client
+	baseURL string
+	token   string
+	event   string
+	states  PageStateGetter
+}
+
+// NewPretalxSpeakerService returns a PretalxSpeakerService sending the requests with the passed Options.
+// If states is not nil the speakers not changed since their last indexing are returned as Unchanged.
+func NewPretalxSpeakerService(p PretalxOptions, states PageStateGetter, opts Options) *PretalxSpeakerService {
+	event := p.Event
+	if event == "" {
+		event = defaultPretalxEvent
+	}
+	return &PretalxSpeakerService{
+		c:       newClient(opts),
+		baseURL: strings.TrimSuffix(p.BaseURL, "/"),
+		token:   p.Token,
+		event:   event,
+		states:  states,
+	}
+}
+
+func (s *PretalxSpeakerService) eventSlug(year int) string {
+	if strings.Contains(s.event, "%d") {
+		return fmt.Sprintf(s.event, year)
+	}
+	return s.event
+}
+
+func (s *PretalxSpeakerService) speakersURL(year int) string {
+	return fmt.Sprintf("%s/api/events/%s/speakers/?limit=%d", s.baseURL, url.PathEscape(s.eventSlug(year)), pretalxPageSize)
+}
+
+// profilePage is the public page of the speaker, unique for every year as the ones of the website
+func (s *PretalxSpeakerService) profilePage(year int, code string) string {
+	return fmt.Sprintf("%s/%s/speaker/%s/", s.baseURL, url.PathEscape(s.eventSlug(year)), url.PathEscape(code))
+}
+
+// GetSpeakers returns the speakers of all the passed years.
+// The channel is closed when all the speakers are returned, or the context is done.
+func (s *PretalxSpeakerService) GetSpeakers(ctx context.Context, years ...int) <-chan Result {
+	return getSpeakers(ctx, s.GetSpeakersByYear, years)
+}
+
+// GetSpeakersByYear returns the speakers of the year, following the pages of the API.
+// The channel is closed when all the speakers are returned, or the context is done.
+func (s *PretalxSpeakerService) GetSpeakersByYear(ctx context.Context, year int) <-chan Result {
+	c := make(chan Result)
+
+	go func() {
+		defer close(c)
+
+		// the pages already fetched, as a next page pointing back to one of them would never end the loop
+		visited := make(map[string]bool)
+		pageURL := s.speakersURL(year)
+		for pageURL != "" {
+			visited[pageURL] = true
+			page, err := s.getPage(ctx, pageURL)
+			if err != nil {
+				send(ctx, c, Result{Error: &PageError{URL: pageURL, Err: err}})
+				return
+			}
+
+			for _, ps := range page.Results {
+				if !send(ctx, c, s.toResult(ps, year)) {
+					return
+				}
+			}
+
+			if err := s.checkNext(visited, page.Next); err != nil {
+				send(ctx, c, Result{Error: &PageError{URL: pageURL, Err: err}})
+				return
+			}
+			pageURL = page.Next
+		}
+	}()
+
+	return c
+}
+
+// checkNext checks the URL of the next page, that must not send the token to another host,
+// or in clear text when the base URL is HTTPS, or be a page already visited, that would never end the loop
+func (s *PretalxSpeakerService) checkNext(visited map[string]bool, next string) error {
+	if next == "" {
+		return nil
+	}
+	if visited[next] {
+		return errors.New("the next page was already visited: " + next)
+	}
+	base, err := url.Parse(s.baseURL)
+	if err != nil {
+		return err
+	}
+	nextURL, err := url.Parse(next)
+	if err != nil {
+		return err
+	}
+	if nextURL.Host != base.Host {
+		return errors.New("the next page is on another host: " + nextURL.Host)
+	}
+	if nextURL.Scheme != base.Scheme {
+		return errors.New("the next page has another scheme: " + nextURL.Scheme)
+	}
+	return nil
+}
+
+// getPage fetches a page of the speakers
+func (s *PretalxSpeakerService) getPage(ctx context.Context, pageURL string) (*pretalxPage, error) {
+	req, err := http.NewRequest(http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Token "+s.token)
+	}
+
+	resp, err := s.c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("error from pretalx: " + strconv.Itoa(resp.StatusCode))
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var page pretalxPage
+	err = json.Unmarshal(b, &page)
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// toResult returns the speaker of the API as a Result. The codes of the submissions are
+// returned as EventSlugs, and the hash of the speaker is used to find the unchanged ones.
+func (s *PretalxSpeakerService) toResult(ps pretalxSpeaker, year int) Result {
+	profilePage := s.profilePage(year, ps.Code)
+
+	b, err := json.Marshal(ps)
+	if err != nil {
+		return Result{Error: &PageError{URL: profilePage, Err: err}}
+	}
+	state := PageState{Hash: hashPage(b)}
+
+	if s.states != nil {
+		if old, found := s.states.GetPageState(profilePage); found && old.Hash == state.Hash {
+			return Result{
+				Speaker: Speaker{
+					Slug:        names.Slug(ps.Name),
+					Name:        ps.Name,
+					ProfilePage: profilePage,
+					Year:        year,
+					Page:        state,
+				},
+				Unchanged: true,
+			}
+		}
+	}
+
+	profileImage := ps.AvatarURL
+	if profileImage == "" {
+		profileImage = ps.Avatar
+	}
+	return Result{
+		Speaker: Speaker{
+			Slug:         names.Slug(ps.Name),
+			Name:         ps.Name,
+			Bio:          ps.Biography,
+			ProfilePage:  profilePage,
+			ProfileImage: profileImage,
+			Year:         year,
+			EventSlugs:   ps.Submissions,
+			Page:         state,
+		},
+	}
+}
@@ -0,0 +1,184 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newPretalxServer serves the speakers of the fosdem-2025 event, two for every page
+func newPretalxServer(t *testing.T, speakers []pretalxSpeaker) *httptest.Server {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/api/events/fosdem-2025/speakers/" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		end := offset + 2
+		page := pretalxPage{Count: len(speakers)}
+		if end < len(speakers) {
+			page.Next = srv.URL + r.URL.Path + "?limit=2&offset=" + strconv.Itoa(end)
+		} else {
+			end = len(speakers)
+		}
+		page.Results = speakers[offset:end]
+		assert.Nil(t, json.NewEncoder(w).Encode(page))
+	}))
+	return srv
+}
+
+type mapStates map[string]PageState
+
+func (m mapStates) GetPageState(profilePage string) (PageState, bool) {
+	s, found := m[profilePage]
+	return s, found
+}
+
+func TestPretalxSpeakers(t *testing.T) {
+	speakers := []pretalxSpeaker{
+		{Code: "ABCDE", Name: "Mario Rossi", Biography: "A bio", Avatar: "https://pretalx.example/avatar.jpg", Submissions: []string{"XYZ12"}},
+		{Code: "FGHIJ", Name: "Paolo Bianchi", AvatarURL: "https://pretalx.example/paolo.jpg"},
+		{Code: "KLMNO", Name: "Nicolò Verdi", Submissions: []string{"XYZ12", "QWE34"}},
+	}
+	srv := newPretalxServer(t, speakers)
+	defer srv.Close()
+
+	service := NewPretalxSpeakerService(PretalxOptions{BaseURL: srv.URL + "/", Token: "secret"}, nil, Options{})
+
+	results := make([]Result, 0)
+	for r := range service.GetSpeakers(context.Background(), 2025) {
+		results = append(results, r)
+	}
+
+	// all the pages are followed
+	assert.Len(t, results, 3)
+	for _, r := range results {
+		assert.Nil(t, r.Error)
+		assert.False(t, r.Unchanged)
+		assert.Equal(t, 2025, r.Speaker.Year)
+		assert.NotEmpty(t, r.Speaker.Page.Hash)
+	}
+	assert.Equal(t, "mario_rossi", results[0].Speaker.Slug)
+	assert.Equal(t, "A bio", results[0].Speaker.Bio)
+	assert.Equal(t, "https://pretalx.example/avatar.jpg", results[0].Speaker.ProfileImage)
+	assert.Equal(t, []string{"XYZ12"}, results[0].Speaker.EventSlugs)
+	assert.Equal(t, srv.URL+"/fosdem-2025/speaker/ABCDE/", results[0].Speaker.ProfilePage)
+	assert.Equal(t, "https://pretalx.example/paolo.jpg", results[1].Speaker.ProfileImage)
+	assert.Equal(t, "nicolo_verdi", results[2].Speaker.Slug)
+
+	// the speakers with the same hash are unchanged
+	states := mapStates{results[1].Speaker.ProfilePage: results[1].Speaker.Page}
+	service = NewPretalxSpeakerService(PretalxOptions{BaseURL: srv.URL, Token: "secret"}, states, Options{})
+	unchanged := 0
+	for r := range service.GetSpeakersByYear(context.Background(), 2025) {
+		assert.Nil(t, r.Error)
+		if r.Unchanged {
+			unchanged++
+			assert.Equal(t, "Paolo Bianchi", r.Speaker.Name)
+			assert.Empty(t, r.Speaker.ProfileImage)
+		}
+	}
+	assert.Equal(t, 1, unchanged)
+}
+
+func TestPretalxSpeakersErrors(t *testing.T) {
+	srv := newPretalxServer(t, []pretalxSpeaker{{Code: "ABCDE", Name: "Mario Rossi"}})
+	defer srv.Close()
+
+	tt := []struct {
+		name    string
+		options PretalxOptions
+		year    int
+	}{
+		{name: "missing event", options: PretalxOptions{BaseURL: srv.URL, Token: "secret"}, year: 2024},
+		{name: "wrong token", options: PretalxOptions{BaseURL: srv.URL, Token: "wrong"}, year: 2025},
+		{name: "unreachable", options: PretalxOptions{BaseURL: "http://127.0.0.1:1", Token: "secret"}, year: 2025},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			results := make([]Result, 0)
+			for r := range NewPretalxSpeakerService(tc.options, nil, Options{}).GetSpeakersByYear(context.Background(), tc.year) {
+				results = append(results, r)
+			}
+			assert.Len(t, results, 1)
+			assert.IsType(t, &PageError{}, results[0].Error)
+		})
+	}
+}
+
+func TestPretalxCheckNext(t *testing.T) {
+	service := NewPretalxSpeakerService(PretalxOptions{BaseURL: "https://pretalx.example"}, nil, Options{})
+	page := "https://pretalx.example/api/events/fosdem-2025/speakers/?limit=100"
+
+	visited := map[string]bool{page: true, page + "&offset=100": true}
+
+	assert.Nil(t, service.checkNext(visited, ""))
+	assert.Nil(t, service.checkNext(visited, page+"&offset=200"))
+	assert.NotNil(t, service.checkNext(visited, page))
+	assert.NotNil(t, service.checkNext(visited, page+"&offset=100"))
+	// the token is never sent to another host
+	assert.NotNil(t, service.checkNext(visited, "https://evil.example/api/events/fosdem-2025/speakers/?offset=200"))
+	// nor in clear text
+	assert.NotNil(t, service.checkNext(visited, "http://pretalx.example/api/events/fosdem-2025/speakers/?offset=200"))
+}
+
+func TestPretalxSpeakersCycle(t *testing.T) {
+	// the second page points back to the first one
+	var srv *httptest.Server
+	var hits int32
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		page := pretalxPage{Next: srv.URL + r.URL.Path + "?limit=100&offset=100"}
+		if r.URL.Query().Get("offset") == "100" {
+			page.Next = srv.URL + r.URL.Path + "?limit=100"
+		}
+		page.Results = []pretalxSpeaker{{Code: "ABCDE" + r.URL.Query().Get("offset"), Name: "Mario Rossi"}}
+		assert.Nil(t, json.NewEncoder(w).Encode(page))
+	}))
+	defer srv.Close()
+
+	service := NewPretalxSpeakerService(PretalxOptions{BaseURL: srv.URL, Event: "fosdem-2025"}, nil, Options{})
+	results := make([]Result, 0)
+	for r := range service.GetSpeakersByYear(context.Background(), 2025) {
+		results = append(results, r)
+	}
+
+	// the pages are fetched once, then the loop is reported
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+	assert.Len(t, results, 3)
+	assert.Nil(t, results[0].Error)
+	assert.Nil(t, results[1].Error)
+	assert.IsType(t, &PageError{}, results[2].Error)
+}
+
+type yearSource int
+
+func (s yearSource) GetSpeakersByYear(ctx context.Context, year int) <-chan Result {
+	c := make(chan Result, 1)
+	c <- Result{Speaker: Speaker{ID: int(s), Year: year}}
+	close(c)
+	return c
+}
+
+func TestSpeakerSources(t *testing.T) {
+	sources := &SpeakerSources{Default: yearSource(1), Years: map[int]SpeakerSource{2025: yearSource(2)}}
+
+	ids := make([]int, 0)
+	for r := range sources.GetSpeakers(context.Background(), 2024, 2025) {
+		ids = append(ids, r.Speaker.ID)
+	}
+	assert.Equal(t, []int{1, 2}, ids)
+}
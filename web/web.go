@@ -2,12 +2,17 @@ package web
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/yhat/scrape"
 	"golang.org/x/net/html"
@@ -19,8 +24,6 @@ const (
 	pathSpeakers = "/schedule/speakers/"
 )
 
-var years = []int{2018}
-
 type Speaker struct {
 	ID           int
 	Slug         string
@@ -30,32 +33,85 @@ type Speaker struct {
 	ProfileImage string
 	Year         int
 	Links        []Link
+	// EventSlugs are the slugs of the events listed in the profile page,
+	// or the codes of the submissions of the speaker in pretalx
+	EventSlugs []string
+	Page       PageState
+}
+
+// PageState identifies the version of a profile page, to avoid scraping it again if not changed
+type PageState struct {
+	ETag         string
+	LastModified string
+	Hash         string
 }
 
+// PageStateGetter returns the state of the profile pages already indexed
+type PageStateGetter interface {
+	GetPageState(profilePage string) (PageState, bool)
+}
+
+// ErrNotModified is returned when a profile page did not change since the last time it was indexed
+var ErrNotModified = errors.New("page not modified")
+
 type Link struct {
 	Title string
 	URL   string
 }
 
+// Result is a scraped speaker. If Unchanged is true the profile page did not change
+// since the last indexing, and only the Slug, the Name, the ProfilePage, the Year and the Page are set:
+// the Page has the validators sent by the server, that could be new for the same content.
 type Result struct {
-	Speaker Speaker
-	Error   error
+	Speaker   Speaker
+	Unchanged bool
+	Error     error
+}
+
+// PageError is the error returned when a page cannot be fetched or parsed
+type PageError struct {
+	URL string
+	Err error
+}
+
+func (e *PageError) Error() string {
+	return e.URL + ": " + e.Err.Error()
+}
+
+func speakersURL(year int) string {
+	return fmt.Sprintf("%s/%d%s", baseURL, year, pathSpeakers)
+}
+
+func speakerURL(profilePage string) string {
+	return baseURL + "/" + strings.TrimPrefix(profilePage, "/")
 }
 
 type speakerGetter interface {
-	GetSpeakersByYear(year int) (io.Reader, error)
-	GetSpeaker(profilePage string) (io.Reader, error)
+	GetSpeakersByYear(ctx context.Context, year int) (io.Reader, error)
+	GetSpeaker(ctx context.Context, profilePage string, state PageState) (io.Reader, PageState, error)
+}
+
+type remoteGetter struct {
+	c *client
 }
 
-type remoteGetter struct{}
+func (g *remoteGetter) GetSpeakersByYear(ctx context.Context, year int) (io.Reader, error) {
+	req, err := http.NewRequest(http.MethodGet, speakersURL(year), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
 
-func (g *remoteGetter) GetSpeakersByYear(year int) (io.Reader, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/%d%s", baseURL, year, pathSpeakers))
+	resp, err := g.c.do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("error from Fosdem server: " + strconv.Itoa(resp.StatusCode))
+	}
+
 	b, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
@@ -63,86 +119,238 @@ func (g *remoteGetter) GetSpeakersByYear(year int) (io.Reader, error) {
 	return bytes.NewReader(b), nil
 }
 
-func (g *remoteGetter) GetSpeaker(profilePage string) (io.Reader, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/%s", baseURL, profilePage))
+// GetSpeaker fetches the profile page with a conditional request,
+// returning ErrNotModified if the page did not change since the passed state
+func (g *remoteGetter) GetSpeaker(ctx context.Context, profilePage string, state PageState) (io.Reader, PageState, error) {
+	req, err := http.NewRequest(http.MethodGet, speakerURL(profilePage), nil)
 	if err != nil {
-		return nil, err
+		return nil, state, err
+	}
+	req = req.WithContext(ctx)
+	if state.ETag != "" {
+		req.Header.Set("If-None-Match", state.ETag)
+	}
+	if state.LastModified != "" {
+		req.Header.Set("If-Modified-Since", state.LastModified)
+	}
+
+	resp, err := g.c.do(req)
+	if err != nil {
+		return nil, state, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, state, ErrNotModified
+	}
+	// the error pages must not be parsed, or saved as the new state
+	if resp.StatusCode != http.StatusOK {
+		return nil, state, errors.New("error from Fosdem server: " + strconv.Itoa(resp.StatusCode))
+	}
+
 	b, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, state, err
 	}
-	return bytes.NewReader(b), nil
+
+	newState := PageState{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Hash:         hashPage(b),
+	}
+	// the server could not support the conditional requests
+	if state.Hash != "" && state.Hash == newState.Hash {
+		return nil, newState, ErrNotModified
+	}
+	return bytes.NewReader(b), newState, nil
+}
+
+func hashPage(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
 }
 
 type SpeakerService struct {
-	g speakerGetter
+	g       speakerGetter
+	states  PageStateGetter
+	workers int
+}
+
+// NewSpeakerService returns a SpeakerService scraping the website with the passed Options
+func NewSpeakerService(opts Options) *SpeakerService {
+	return &SpeakerService{
+		g:       &remoteGetter{c: newClient(opts)},
+		workers: opts.Workers,
+	}
+}
+
+// NewIncrementalSpeakerService returns a SpeakerService that fetches again only the
+// profile pages changed since the state returned by the PageStateGetter
+func NewIncrementalSpeakerService(states PageStateGetter, opts Options) *SpeakerService {
+	srv := NewSpeakerService(opts)
+	srv.states = states
+	return srv
 }
 
-func NewSpeakerService() *SpeakerService {
-	return &SpeakerService{&remoteGetter{}}
+// GetSpeakers returns the speakers of all the passed years.
+// The channel is closed when all the speakers are returned, or the context is done.
+func (w *SpeakerService) GetSpeakers(ctx context.Context, years ...int) <-chan Result {
+	return getSpeakers(ctx, w.GetSpeakersByYear, years)
 }
 
-func (w *SpeakerService) GetSpeakers() <-chan Result {
+// getSpeakers returns the speakers of all the years, one year after the other
+func getSpeakers(ctx context.Context, byYear func(ctx context.Context, year int) <-chan Result, years []int) <-chan Result {
 	c := make(chan Result)
 	go func() {
+		defer close(c)
 		for _, y := range years {
-			for cY := range w.GetSpeakersByYear(y) {
-				c <- cY
+			for cY := range byYear(ctx, y) {
+				if !send(ctx, c, cY) {
+					return
+				}
 			}
 		}
 	}()
 	return c
 }
 
-func (w *SpeakerService) GetSpeakersByYear(year int) <-chan Result {
+// SpeakerSource returns the speakers of an edition, as the SpeakerService
+type SpeakerSource interface {
+	GetSpeakersByYear(ctx context.Context, year int) <-chan Result
+}
+
+// SpeakerSources reads the speakers of every edition from its own source,
+// i.e. the API of pretalx for the recent years and the website for the older ones
+type SpeakerSources struct {
+	// Default is the source of the years not in Years
+	Default SpeakerSource
+	Years   map[int]SpeakerSource
+}
+
+// GetSpeakers returns the speakers of all the passed years.
+// The channel is closed when all the speakers are returned, or the context is done.
+func (s *SpeakerSources) GetSpeakers(ctx context.Context, years ...int) <-chan Result {
+	return getSpeakers(ctx, s.GetSpeakersByYear, years)
+}
+
+// GetSpeakersByYear returns the speakers of the year from its source
+func (s *SpeakerSources) GetSpeakersByYear(ctx context.Context, year int) <-chan Result {
+	if source, found := s.Years[year]; found {
+		return source.GetSpeakersByYear(ctx, year)
+	}
+	return s.Default.GetSpeakersByYear(ctx, year)
+}
+
+// GetSpeakersByYear returns the speakers of the year. The profile pages are fetched
+// in parallel by the workers, so the results are not sorted.
+// The channel is closed when all the speakers are returned, or the context is done:
+// the requests in flight are cancelled, and the remaining pages are not fetched.
+func (w *SpeakerService) GetSpeakersByYear(ctx context.Context, year int) <-chan Result {
 	c := make(chan Result)
 
 	go func() {
-		reader, err := w.g.GetSpeakersByYear(year)
+		defer close(c)
+
+		reader, err := w.g.GetSpeakersByYear(ctx, year)
 		if err != nil {
-			c <- Result{Error: err}
+			send(ctx, c, Result{Error: &PageError{URL: speakersURL(year), Err: err}})
+			return
 		}
 
 		speakers, err := parseSpeakers(reader)
 		if err != nil {
-			c <- Result{Error: err}
+			send(ctx, c, Result{Error: &PageError{URL: speakersURL(year), Err: err}})
+			return
 		}
 
-		for _, s := range speakers {
-			reader, err := w.g.GetSpeaker(s.ProfilePage)
-			if err != nil {
-				c <- Result{Error: err}
-				continue
-			}
+		workers := w.workers
+		if workers < 1 {
+			workers = 1
+		}
 
-			speaker, err := parseSpeaker(reader)
-			if err != nil {
-				c <- Result{Error: err}
-				continue
-			}
+		queue := make(chan Speaker)
+		var wg sync.WaitGroup
+		wg.Add(workers)
+		for i := 0; i < workers; i++ {
+			go func() {
+				defer wg.Done()
+				for s := range queue {
+					if !send(ctx, c, w.getSpeaker(ctx, s, year)) {
+						return
+					}
+				}
+			}()
+		}
 
-			c <- Result{
-				Speaker: Speaker{
-					Slug:         getSlugByLink(s.ProfilePage),
-					Name:         s.Name,
-					Bio:          speaker.Bio,
-					ProfilePage:  s.ProfilePage,
-					ProfileImage: speaker.ProfileImage,
-					Year:         year,
-					Links:        speaker.Links,
-				},
+	enqueue:
+		for _, s := range speakers {
+			select {
+			case queue <- s:
+			case <-ctx.Done():
+				break enqueue
 			}
 		}
-
-		close(c)
+		close(queue)
+		wg.Wait()
 	}()
 
 	return c
 }
 
+// send sends the result, returning false if the context is done before it is received
+func send(ctx context.Context, c chan<- Result, r Result) bool {
+	select {
+	case c <- r:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// getSpeaker fetches and parses the profile page of the speaker found in the list
+func (w *SpeakerService) getSpeaker(ctx context.Context, s Speaker, year int) Result {
+	var state PageState
+	if w.states != nil {
+		state, _ = w.states.GetPageState(s.ProfilePage)
+	}
+
+	reader, newState, err := w.g.GetSpeaker(ctx, s.ProfilePage, state)
+	if err == ErrNotModified {
+		return Result{
+			Speaker: Speaker{
+				Slug:        getSlugByLink(s.ProfilePage),
+				Name:        s.Name,
+				ProfilePage: s.ProfilePage,
+				Year:        year,
+				Page:        newState,
+			},
+			Unchanged: true,
+		}
+	}
+	if err != nil {
+		return Result{Error: &PageError{URL: speakerURL(s.ProfilePage), Err: err}}
+	}
+
+	speaker, err := parseSpeaker(reader)
+	if err != nil {
+		return Result{Error: &PageError{URL: speakerURL(s.ProfilePage), Err: err}}
+	}
+
+	return Result{
+		Speaker: Speaker{
+			Slug:         getSlugByLink(s.ProfilePage),
+			Name:         s.Name,
+			Bio:          speaker.Bio,
+			ProfilePage:  s.ProfilePage,
+			ProfileImage: speaker.ProfileImage,
+			Year:         year,
+			Links:        speaker.Links,
+			EventSlugs:   speaker.EventSlugs,
+			Page:         newState,
+		},
+	}
+}
+
 //ParseSpeakersPage returns a map SpeakerName to DetailPageLink of the speakers
 func parseSpeakers(htmlPage io.Reader) ([]Speaker, error) {
 	root, err := html.Parse(htmlPage)
@@ -211,6 +419,16 @@ func parseSpeaker(htmlPage io.Reader) (*Speaker, error) {
 		}
 	}
 
+	speaker.EventSlugs = make([]string, 0)
+	seen := make(map[string]bool)
+	for _, aNode := range scrape.FindAll(mainDiv, eventLinkMatcher) {
+		slug := getSlugByLink(scrape.Attr(aNode, "href"))
+		if slug != "" && !seen[slug] {
+			seen[slug] = true
+			speaker.EventSlugs = append(speaker.EventSlugs, slug)
+		}
+	}
+
 	return speaker, nil
 }
 
@@ -223,6 +441,10 @@ func pMatcher(n *html.Node) bool   { return n.DataAtom == atom.P }
 func imgMatcher(n *html.Node) bool { return n.DataAtom == atom.Img }
 func ulMatcher(n *html.Node) bool  { return n.DataAtom == atom.Ul }
 
+func eventLinkMatcher(n *html.Node) bool {
+	return n.DataAtom == atom.A && strings.Contains(scrape.Attr(n, "href"), "/schedule/event/")
+}
+
 func getSlugByLink(detailLink string) string {
 	if detailLink == "" {
 		return ""
@@ -1,10 +1,15 @@
 package web
 
 import (
+	"context"
 	"errors"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -23,12 +28,13 @@ func (g *localGetter) readHTML(page string, err error) (io.Reader, error) {
 	return os.Open(page)
 }
 
-func (g *localGetter) GetSpeakersByYear(year int) (io.Reader, error) {
+func (g *localGetter) GetSpeakersByYear(ctx context.Context, year int) (io.Reader, error) {
 	return g.readHTML(g.speakersHTMLPage, g.errSpeakers)
 }
 
-func (g *localGetter) GetSpeaker(profilePage string) (io.Reader, error) {
-	return g.readHTML(g.speakerHTMLPage, g.errSpeaker)
+func (g *localGetter) GetSpeaker(ctx context.Context, profilePage string, state PageState) (io.Reader, PageState, error) {
+	r, err := g.readHTML(g.speakerHTMLPage, g.errSpeaker)
+	return r, state, err
 }
 
 func TestGetSpeakers(t *testing.T) {
@@ -52,6 +58,7 @@ func TestGetSpeakers(t *testing.T) {
 					ProfileImage: "/2018/schedule/speaker/francesc_campoy/cac4fd830f6d7dd839e1a8cd77ad17c9f5ba9bb39b9c2bc44b05f4568a72a1b6.jpg",
 					Year:         2018,
 					Links:        []Link{Link{Title: "justforfunc", URL: "http://justforfunc.com"}},
+					EventSlugs:   []string{"stateofgo", "lightning", "code_babelfish_a_universal_code_parser_for_source_code_analysis"},
 				},
 			}},
 		},
@@ -60,14 +67,17 @@ func TestGetSpeakers(t *testing.T) {
 			speakerGetter: &localGetter{
 				errSpeakers: errors.New("error from speakers"),
 			},
-			expectedResults: []Result{{Error: errors.New("error from speakers")}},
+			expectedResults: []Result{{Error: &PageError{
+				URL: "https://fosdem.org/2018/schedule/speakers/",
+				Err: errors.New("error from speakers"),
+			}}},
 		},
 	}
 
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
-			srv := &SpeakerService{tc.speakerGetter}
-			resultChan := srv.GetSpeakers()
+			srv := &SpeakerService{g: tc.speakerGetter}
+			resultChan := srv.GetSpeakers(context.Background(), 2018)
 
 			results := make([]Result, 0)
 			for r := range resultChan {
@@ -78,3 +88,95 @@ func TestGetSpeakers(t *testing.T) {
 		})
 	}
 }
+
+// blockingGetter returns the speakers list, and blocks on the profile pages until the context is done
+type blockingGetter struct {
+	localGetter
+}
+
+func (g *blockingGetter) GetSpeaker(ctx context.Context, profilePage string, state PageState) (io.Reader, PageState, error) {
+	<-ctx.Done()
+	return nil, state, ctx.Err()
+}
+
+func TestGetSpeakersCancelled(t *testing.T) {
+	srv := &SpeakerService{
+		g:       &blockingGetter{localGetter{speakersHTMLPage: "speakers_1.htm"}},
+		workers: 4,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	resultChan := srv.GetSpeakers(ctx, 2017, 2018)
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	// the channel is closed after the cancellation, without waiting for the other year
+	done := make(chan struct{})
+	go func() {
+		for range resultChan {
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the channel was not closed after the cancellation")
+	}
+}
+
+// hostTransport sends the requests to the host of the test server
+type hostTransport struct {
+	host string
+}
+
+func (t hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = "http"
+	req.URL.Host = t.host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestRemoteGetterStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/2018/schedule/speakers/", "/2018/schedule/speaker/mario_rossi/":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			w.Write([]byte("<html></html>"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("<html>not found</html>"))
+		}
+	}))
+	defer srv.Close()
+
+	c := newClient(Options{})
+	c.httpClient = &http.Client{Transport: hostTransport{strings.TrimPrefix(srv.URL, "http://")}}
+	g := &remoteGetter{c: c}
+
+	_, err := g.GetSpeakersByYear(context.Background(), 2018)
+	assert.Nil(t, err)
+	_, err = g.GetSpeakersByYear(context.Background(), 2017)
+	assert.NotNil(t, err)
+
+	_, state, err := g.GetSpeaker(context.Background(), "/2018/schedule/speaker/mario_rossi/", PageState{})
+	assert.Nil(t, err)
+	assert.Equal(t, `"v1"`, state.ETag)
+	_, _, err = g.GetSpeaker(context.Background(), "/2018/schedule/speaker/mario_rossi/", state)
+	assert.Equal(t, ErrNotModified, err)
+
+	// the same page with a new ETag returns the new validators
+	_, refreshed, err := g.GetSpeaker(context.Background(), "/2018/schedule/speaker/mario_rossi/", PageState{ETag: `"v0"`, Hash: state.Hash})
+	assert.Equal(t, ErrNotModified, err)
+	assert.Equal(t, state, refreshed)
+
+	// the error page is not returned, and the state is kept
+	old := PageState{ETag: `"v0"`, Hash: "abc"}
+	r, state, err := g.GetSpeaker(context.Background(), "/2018/schedule/speaker/paolo_bianchi/", old)
+	assert.NotNil(t, err)
+	assert.NotEqual(t, ErrNotModified, err)
+	assert.Nil(t, r)
+	assert.Equal(t, old, state)
+}